@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+)
+
+func main() {
+	logPath := flag.String("log", gamelogic.EventLogPath(), "event log recorded by the server")
+	mapPath := flag.String("map", "", "map file the game was played on (default: the built-in world map)")
+	player := flag.String("player", "", "show this player's game after each event")
+	to := flag.Uint64("to", 0, "jump straight to the event with this number")
+	step := flag.Bool("step", false, "wait for Enter before each event")
+	delay := flag.Duration("delay", 0, "pause between events")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: peril-replay [flags]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	events, err := gamelogic.ReadEvents(*logPath)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	fmt.Printf("Replaying %d event(s) from %s\n", len(events), *logPath)
+
+	board := gamelogic.DefaultMap()
+	if *mapPath != "" {
+		board, err = gamelogic.LoadMap(*mapPath)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+	}
+	replay, err := gamelogic.NewReplay(board, events)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	if *to > 0 {
+		replay.StepTo(*to)
+		fmt.Printf("Skipped to event #%d.\n", *to)
+		printState(replay, *player)
+	}
+
+	for {
+		if *step {
+			words := gamelogic.GetInput()
+			if words == nil || (len(words) > 0 && words[0] == "quit") {
+				return
+			}
+		}
+		e, ok := replay.Step()
+		if !ok {
+			break
+		}
+		fmt.Println(e)
+		if *player != "" {
+			printState(replay, *player)
+		}
+		time.Sleep(*delay)
+	}
+
+	fmt.Println("End of the game so far.")
+	printState(replay, *player)
+}
+
+// printState shows player's game, or everyone's if player is empty.
+func printState(replay *gamelogic.Replay, player string) {
+	players := []string{player}
+	if player == "" {
+		players = replay.Players()
+	}
+	for _, p := range players {
+		replay.GameState(p).CommandStatus()
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+func commandDLQ(ctx context.Context, ch pubsub.Channel, words []string) error {
+	if len(words) < 2 {
+		return fmt.Errorf("usage: dlq list | show <n> | replay <n|all> | purge")
+	}
+	switch words[1] {
+	case "list":
+		dls, err := pubsub.ListDeadLetters(ch, routing.QueuePerilDLQ)
+		if err != nil {
+			return err
+		}
+		if len(dls) == 0 {
+			fmt.Println("The dead-letter queue is empty.")
+			return nil
+		}
+		for i, dl := range dls {
+			fmt.Printf("%d. [%s] %s from %s, died in %s (%s %s)\n",
+				i+1, dl.Reason, typeOrUnknown(dl), senderOrUnknown(dl), dl.Queue, dl.Exchange, dl.RoutingKey)
+		}
+		return nil
+	case "show":
+		n, err := dlqPosition(words)
+		if err != nil {
+			return err
+		}
+		dls, err := pubsub.ListDeadLetters(ch, routing.QueuePerilDLQ)
+		if err != nil {
+			return err
+		}
+		if n >= len(dls) {
+			return fmt.Errorf("there is no dead letter %d, the queue has %d", n+1, len(dls))
+		}
+		dl := dls[n]
+		fmt.Printf("Type:        %s\n", typeOrUnknown(dl))
+		fmt.Printf("Message ID:  %s\n", dl.Envelope.MessageID)
+		fmt.Printf("Sender:      %s\n", senderOrUnknown(dl))
+		fmt.Printf("Reason:      %s\n", dl.Reason)
+		fmt.Printf("Died in:     %s (%d times)\n", dl.Queue, dl.Deaths)
+		fmt.Printf("Replays to:  %s %s\n", dl.Exchange, dl.RoutingKey)
+		fmt.Println(dl.Body())
+		return nil
+	case "replay":
+		var positions []int
+		if len(words) < 3 || words[2] != "all" {
+			n, err := dlqPosition(words)
+			if err != nil {
+				return err
+			}
+			positions = append(positions, n)
+		}
+		replayed, err := pubsub.ReplayDeadLetters(ctx, ch, routing.QueuePerilDLQ, positions...)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Replayed %d dead letters.\n", replayed)
+		return nil
+	case "purge":
+		n, err := ch.QueuePurge(routing.QueuePerilDLQ, false)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Purged %d dead letters.\n", n)
+		return nil
+	}
+	return fmt.Errorf("unknown dlq command %q", words[1])
+}
+
+// dlqPosition reads the 1-based position the list command prints and
+// returns it 0-based.
+func dlqPosition(words []string) (int, error) {
+	if len(words) < 3 {
+		return 0, fmt.Errorf("usage: dlq %s <n>", words[1])
+	}
+	n, err := strconv.Atoi(words[2])
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid dead letter number %q", words[2])
+	}
+	return n - 1, nil
+}
+
+func typeOrUnknown(dl pubsub.DeadLetter) string {
+	if dl.Envelope.Type == "" {
+		return "unknown message"
+	}
+	return dl.Envelope.Type
+}
+
+func senderOrUnknown(dl pubsub.DeadLetter) string {
+	if dl.Envelope.Sender == "" {
+		return "unknown sender"
+	}
+	return dl.Envelope.Sender
+}
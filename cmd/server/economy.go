@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+)
+
+// payIncome pays every player their income and checks for a winner once per
+// tick, until ctx is done or the game is over. In turn mode this happens at
+// the end of each round instead.
+func payIncome(ctx context.Context, ch pubsub.Publisher, world *gamelogic.World, tick time.Duration) {
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	for {
+		if _, over := world.Over(); over {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		// Income arrives every tick, so it updates the treasury quietly.
+		paid := world.PayIncome()
+		for _, user := range slices.Sorted(maps.Keys(paid)) {
+			reportErr(sendUpdate(ctx, ch, world, user, "", false))
+		}
+		if over, ok := world.CheckVictory(); ok {
+			announceGameOver(ctx, ch, over)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// rounds collects orders for the current round in turn mode.
+type rounds struct {
+	length time.Duration
+
+	mu     sync.Mutex
+	number int
+	orders []gamelogic.Order
+}
+
+func newRounds(length time.Duration) *rounds {
+	return &rounds{length: length}
+}
+
+// add queues order for the current round and returns the round's number.
+func (r *rounds) add(order gamelogic.Order) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.orders = append(r.orders, order)
+	// Orders sent before the first round starts are kept for it.
+	return max(r.number, 1)
+}
+
+// next ends the current round, returning its orders, and starts the next.
+func (r *rounds) next() (int, []gamelogic.Order, gamelogic.Round) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	number, orders := r.number, r.orders
+	r.number++
+	if number > 0 {
+		r.orders = nil
+	}
+	return number, orders, gamelogic.Round{
+		Number:   r.number,
+		Deadline: time.Now().Add(r.length),
+	}
+}
+
+// run announces a round, waits for it to end and carries out its orders,
+// until ctx is done or the game is over. Orders that arrive while a round is
+// being resolved go to the next one.
+func (r *rounds) run(ctx context.Context, ch pubsub.Publisher, world *gamelogic.World) {
+	for {
+		number, orders, round := r.next()
+		if number > 0 {
+			result := world.ResolveRound(number, orders)
+			publishRound(ctx, ch, world, result, world.PayIncome())
+			if over, ok := world.CheckVictory(); ok {
+				announceGameOver(ctx, ch, over)
+			}
+		}
+		if _, over := world.Over(); over {
+			return
+		}
+		err := pubsub.PublishProto(ctx, ch, routing.ExchangePerilDirect, routing.RoundKey, round)
+		if err != nil {
+			fmt.Println(err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(round.Deadline)):
+		}
+	}
+}
+
+// publishRound tells everyone what happened in a round: the moves made,
+// each player's army with what became of their orders and what they were
+// paid, and the wars.
+func publishRound(ctx context.Context, ch pubsub.Publisher, world *gamelogic.World, result gamelogic.RoundResult, paid map[string]int) {
+	messages := map[string][]string{}
+	rejected := map[string]bool{}
+	players := []string{}
+	for _, o := range result.Orders {
+		user := o.Order.Username
+		if _, ok := messages[user]; !ok {
+			players = append(players, user)
+		}
+		switch {
+		case o.Err != nil:
+			messages[user] = append(messages[user], fmt.Sprintf("Rejected: %v", o.Err))
+			rejected[user] = true
+		case o.Order.Spawn != nil:
+			messages[user] = append(messages[user], fmt.Sprintf("Spawned a(n) %s in %s with id %v", o.Unit.Rank, o.Unit.Location, o.Unit.ID))
+		default:
+			messages[user] = append(messages[user], fmt.Sprintf("Moved %v units to %s", len(o.Move.Units), o.Move.ToLocation))
+			reportErr(pubsub.PublishProto(ctx, ch, routing.ExchangePerilTopic, routing.ArmyMoveKey(user), o.Move))
+		}
+	}
+
+	for _, user := range slices.Sorted(maps.Keys(paid)) {
+		if _, ok := messages[user]; !ok {
+			players = append(players, user)
+		}
+		messages[user] = append(messages[user], fmt.Sprintf("Earned %d gold", paid[user]))
+	}
+
+	for _, user := range players {
+		header := fmt.Sprintf("Round %d results:", result.Number)
+		msg := strings.Join(append([]string{header}, messages[user]...), "\n")
+		reportErr(sendUpdate(ctx, ch, world, user, msg, rejected[user]))
+	}
+	for _, war := range result.Wars {
+		reportErr(sendWar(ctx, ch, war))
+	}
+}
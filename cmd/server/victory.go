@@ -0,0 +1,20 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// announceGameOver tells every player how the game ended and logs it.
+func announceGameOver(ctx context.Context, ch pubsub.Publisher, over gamelogic.GameOver) {
+	fmt.Println()
+	fmt.Println("==== Game Over ====")
+	fmt.Println(over)
+	fmt.Print("> ")
+	reportErr(pubsub.PublishProto(ctx, ch, routing.ExchangePerilDirect, routing.GameOverKey, over))
+	reportErr(pubsub.PublishGameLog(ctx, ch, over.Standings[0].Player, "Game over. "+over.Reason))
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// handlerOrder carries out a player's order against the world and tells
+// everyone affected. Once the world has changed the order is acked even if
+// telling them fails: running it again would spawn or fight twice, and
+// clients catch up from the world when they next join. In turn mode, when
+// turns is not nil, the order is queued for the end of the round instead.
+func handlerOrder(world *gamelogic.World, turns *rounds, ch pubsub.Publisher) func(context.Context, gamelogic.Order) pubsub.Acktype {
+	return func(ctx context.Context, order gamelogic.Order) pubsub.Acktype {
+		defer fmt.Print("> ")
+		env, _ := pubsub.EnvelopeFromContext(ctx)
+		user, err := env.KeyUser()
+		if err != nil || user != order.Username {
+			fmt.Printf("Discarding an order for %q routed with %q\n", order.Username, env.RoutingKey)
+			return pubsub.NackDiscard
+		}
+
+		if turns != nil {
+			n := turns.add(order)
+			reportErr(sendUpdate(ctx, ch, world, user, fmt.Sprintf("Your order will be carried out at the end of round %d", n), false))
+			return pubsub.Ack
+		}
+
+		switch {
+		case order.Spawn != nil:
+			unit, err := world.Spawn(user, *order.Spawn)
+			if err != nil {
+				reportErr(sendUpdate(ctx, ch, world, user, fmt.Sprintf("Could not spawn: %v", err), true))
+				return pubsub.Ack
+			}
+			msg := fmt.Sprintf("Spawned a(n) %s in %s with id %v", unit.Rank, unit.Location, unit.ID)
+			reportErr(sendUpdate(ctx, ch, world, user, msg, false))
+		case order.Move != nil:
+			move, wars, err := world.Move(user, *order.Move)
+			if err != nil {
+				reportErr(sendUpdate(ctx, ch, world, user, fmt.Sprintf("Could not move: %v", err), true))
+				return pubsub.Ack
+			}
+			reportErr(pubsub.PublishProto(ctx, ch, routing.ExchangePerilTopic, routing.ArmyMoveKey(user), move))
+			for _, war := range wars {
+				reportErr(sendWar(ctx, ch, war))
+			}
+			// The army after the wars. The client gets the update and the
+			// verdicts on different queues, in either order, and applying a
+			// verdict to an army that has already lost the casualties changes
+			// nothing.
+			reportErr(sendUpdate(ctx, ch, world, user, fmt.Sprintf("Moved %v units to %s", len(move.Units), move.ToLocation), false))
+		default:
+			fmt.Printf("Discarding an empty order from %s\n", user)
+			return pubsub.NackDiscard
+		}
+		return pubsub.Ack
+	}
+}
+
+// sendWar announces the verdict to every player, both sides included, and
+// logs the same outcome.
+func sendWar(ctx context.Context, ch pubsub.Publisher, war gamelogic.WarResult) error {
+	err := pubsub.PublishProto(ctx, ch, routing.ExchangePerilTopic, routing.WarKey(war.Attacker), war)
+	if err != nil {
+		return err
+	}
+	var msg string
+	if war.Winner == "" {
+		msg = fmt.Sprintf("A war between %s and %s in %s resulted in a draw", war.Attacker, war.Defender, war.Location)
+	} else {
+		msg = fmt.Sprintf("%s won a war against %s in %s", war.Winner, war.Loser(), war.Location)
+	}
+	return pubsub.PublishGameLog(ctx, ch, war.Attacker, msg)
+}
+
+func sendUpdate(ctx context.Context, ch pubsub.Publisher, world *gamelogic.World, user, msg string, rejected bool) error {
+	return pubsub.PublishProto(ctx, ch, routing.ExchangePerilTopic, routing.PlayerUpdateKey(user), playerUpdate(world, user, msg, rejected))
+}
+
+// playerUpdate is user's army, home and treasury as the world has them.
+func playerUpdate(world *gamelogic.World, user, msg string, rejected bool) gamelogic.PlayerUpdate {
+	home := world.Home(user)
+	return gamelogic.PlayerUpdate{
+		Player:      world.Player(user),
+		Message:     msg,
+		Rejected:    rejected,
+		Home:        home,
+		Treasury:    world.Treasury(user),
+		Income:      world.Income(user),
+		Territories: world.Territories(user),
+	}
+}
+
+// handlerPlayerState gives a joining client its army as the world has it.
+func handlerPlayerState(world *gamelogic.World) func(context.Context, routing.GameStateQuery) (gamelogic.PlayerUpdate, error) {
+	return func(_ context.Context, q routing.GameStateQuery) (gamelogic.PlayerUpdate, error) {
+		err := routing.ValidateUsername(q.Username)
+		if err != nil {
+			return gamelogic.PlayerUpdate{}, err
+		}
+		update := playerUpdate(world, q.Username, "", false)
+		update.Message = fmt.Sprintf("You have %d unit(s) and %d gold.", len(update.Player.Units), update.Treasury)
+		if over, ok := world.Over(); ok {
+			update.Message += "\nThe game is over. " + over.Reason
+		}
+		return update, nil
+	}
+}
+
+// handlerMap gives a joining client the board, so it checks orders against
+// the same one as the server.
+func handlerMap(board *gamelogic.Map) func(context.Context, routing.GameStateQuery) (gamelogic.Map, error) {
+	return func(context.Context, routing.GameStateQuery) (gamelogic.Map, error) {
+		return *board, nil
+	}
+}
+
+func reportErr(err error) {
+	if err != nil {
+		fmt.Println(err.Error())
+	}
+}
@@ -1,5 +1,16 @@
 module github.com/bootdotdev/learn-pub-sub-starter
 
-go 1.22.1
+go 1.23
 
-require github.com/rabbitmq/amqp091-go v1.10.0 // indirect
+require (
+	github.com/fxamacker/cbor/v2 v2.9.4
+	github.com/rabbitmq/amqp091-go v1.10.0
+	github.com/vmihailenco/msgpack/v5 v5.4.1
+	google.golang.org/protobuf v1.36.9
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+	github.com/x448/float16 v0.8.4 // indirect
+)
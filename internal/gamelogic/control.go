@@ -0,0 +1,59 @@
+package gamelogic
+
+import "slices"
+
+// Owner is who controls loc, or empty if nobody has yet. A player takes a
+// territory by being the only one with units in it, whether they moved in
+// unopposed or won a war there, and keeps it after their units leave until
+// someone else takes it. A draw leaves it with whoever held it before.
+func (w *World) Owner(loc Location) string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.owners[loc]
+}
+
+// Territories lists the territories username controls, in map order.
+func (w *World) Territories(username string) []Location {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.controlled(username)
+}
+
+func (w *World) controlled(username string) []Location {
+	locs := []Location{}
+	for _, loc := range w.m.Locations() {
+		if w.owners[loc] == username {
+			locs = append(locs, loc)
+		}
+	}
+	return locs
+}
+
+// updateOwners hands every territory held by a single player's units to
+// that player.
+func (w *World) updateOwners() {
+	for _, loc := range w.m.Locations() {
+		holders := []string{}
+		for name, p := range w.players {
+			if len(unitsAt(p, loc)) > 0 {
+				holders = append(holders, name)
+			}
+		}
+		if len(holders) == 1 {
+			w.owners[loc] = holders[0]
+		}
+	}
+}
+
+// eliminated reports whether username has neither units nor territories.
+func (w *World) eliminated(username string) bool {
+	return len(w.players[username].Units) == 0 && len(w.controlled(username)) == 0
+}
+
+// Territories are the ones the player controls, as of the server's last
+// update.
+func (gs *GameState) Territories() []Location {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return slices.Clone(gs.territories)
+}
@@ -0,0 +1,110 @@
+package gamelogic
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+const (
+	// StartingTreasury is what a player has before their first income.
+	StartingTreasury = 10
+	// BaseIncome is paid every tick even to players who control nothing, so
+	// nobody is locked out of the game for good.
+	BaseIncome = 1
+)
+
+var ErrInsufficientFunds = errors.New("not enough gold")
+
+// UnitCost is the gold it takes to spawn a unit of rank, in line with how
+// much it adds to unitsToPowerLevel.
+func UnitCost(rank UnitRank) int {
+	switch rank {
+	case RankArtillery:
+		return 6
+	case RankCavalry:
+		return 3
+	case RankInfantry:
+		return 1
+	}
+	return 0
+}
+
+// Treasury is how much gold username has.
+func (w *World) Treasury(username string) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.balance(username)
+}
+
+func (w *World) balance(username string) int {
+	if _, ok := w.players[username]; !ok {
+		return StartingTreasury
+	}
+	return w.treasury[username]
+}
+
+// Income is what username earns each tick: BaseIncome, plus the income of
+// every territory they control, plus the bonus of every region whose
+// territories they all control.
+func (w *World) Income(username string) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.income(username)
+}
+
+func (w *World) income(username string) int {
+	controlled := w.controlled(username)
+	income := BaseIncome
+	for _, loc := range controlled {
+		t, _ := w.m.Territory(loc)
+		income += t.Income
+	}
+	for _, r := range w.m.Regions {
+		if !slices.ContainsFunc(r.Territories, func(loc Location) bool { return !slices.Contains(controlled, loc) }) {
+			income += r.Bonus
+		}
+	}
+	return income
+}
+
+// PayIncome adds every player's income to their treasury and returns what
+// each was paid. Nobody is paid while the game is paused or once it is over.
+func (w *World) PayIncome() map[string]int {
+	defer w.changed()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.paused || w.over != nil {
+		return nil
+	}
+	paid := make(map[string]int, len(w.players))
+	for name := range w.players {
+		paid[name] = w.income(name)
+		w.treasury[name] += paid[name]
+	}
+	w.record(Event{Kind: EventIncome, Income: paid})
+	return paid
+}
+
+// afford fails if username cannot pay for a unit of rank.
+func (w *World) afford(username string, rank UnitRank) error {
+	cost, gold := UnitCost(rank), w.balance(username)
+	if gold < cost {
+		return fmt.Errorf("%w: a(n) %s costs %d, %s has %d", ErrInsufficientFunds, rank, cost, username, gold)
+	}
+	return nil
+}
+
+// Treasury is how much gold the player has, as of the server's last update.
+func (gs *GameState) Treasury() int {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return gs.treasury
+}
+
+// Income is what the player earns each tick, as of the server's last update.
+func (gs *GameState) Income() int {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return gs.income
+}
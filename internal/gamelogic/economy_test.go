@@ -0,0 +1,115 @@
+package gamelogic
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestWorldAffordability(t *testing.T) {
+	w := NewWorld()
+	// 6 + 3 + 1 spends the starting 10 gold exactly.
+	for _, rank := range []UnitRank{RankArtillery, RankCavalry, RankInfantry} {
+		if _, err := w.Spawn("alice", Spawn{Rank: rank, Location: "europe"}); err != nil {
+			t.Fatalf("spawning a(n) %s: %v", rank, err)
+		}
+	}
+	if gold := w.Treasury("alice"); gold != 0 {
+		t.Fatalf("treasury = %d, want 0", gold)
+	}
+	if _, err := w.Spawn("alice", Spawn{Rank: RankInfantry, Location: "europe"}); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("spawning with no gold: got %v, want ErrInsufficientFunds", err)
+	}
+
+	w.PayIncome()
+	if _, err := w.Spawn("alice", Spawn{Rank: RankInfantry, Location: "europe"}); err != nil {
+		t.Errorf("spawning after income: %v", err)
+	}
+}
+
+func TestWorldIncome(t *testing.T) {
+	tests := []struct {
+		name string
+		held []Location
+		want int
+	}{
+		{"nothing", nil, BaseIncome},
+		{"one territory", []Location{"europe"}, BaseIncome + 3},
+		{"part of a region", []Location{"europe", "africa"}, BaseIncome + 3 + 2},
+		{"a whole region", []Location{"americas", "europe", "africa"}, BaseIncome + 3 + 3 + 2 + 2},
+		{"another region", []Location{"asia", "australia"}, BaseIncome + 3 + 1 + 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWorld()
+			for _, loc := range tt.held {
+				if _, err := w.Spawn("alice", Spawn{Rank: RankInfantry, Location: loc}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := w.Income("alice"); got != tt.want {
+				t.Errorf("income = %d, want %d", got, tt.want)
+			}
+
+			gold := w.Treasury("alice")
+			paid := w.PayIncome()
+			if len(tt.held) > 0 && paid["alice"] != tt.want {
+				t.Errorf("paid %d, want %d", paid["alice"], tt.want)
+			}
+			if len(tt.held) > 0 && w.Treasury("alice") != gold+tt.want {
+				t.Errorf("treasury = %d after income, want %d", w.Treasury("alice"), gold+tt.want)
+			}
+		})
+	}
+}
+
+func TestWorldPaysNobodyWhenStopped(t *testing.T) {
+	w := newTurnWorld(t)
+	w.SetPaused(true)
+	if paid := w.PayIncome(); paid != nil {
+		t.Errorf("paid %v while paused", paid)
+	}
+	w.SetPaused(false)
+	w.over = &GameOver{}
+	if paid := w.PayIncome(); paid != nil {
+		t.Errorf("paid %v after the game ended", paid)
+	}
+}
+
+func TestWorldOwnershipTransfer(t *testing.T) {
+	w := newTurnWorld(t)
+	if owner := w.Owner("europe"); owner != "alice" {
+		t.Fatalf("europe is held by %q, want alice", owner)
+	}
+
+	// Alice keeps europe after leaving it.
+	if _, _, err := w.Move("alice", ArmyMove{Units: []Unit{{ID: 1}}, ToLocation: "africa"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := w.Territories("alice"), []Location{"europe", "africa"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("alice holds %v, want %v", got, want)
+	}
+	aliceIncome := w.Income("alice")
+
+	// Bob takes it by moving in, and its income with it.
+	if _, _, err := w.Move("bob", ArmyMove{Units: []Unit{{ID: 1}}, ToLocation: "europe"}); err != nil {
+		t.Fatal(err)
+	}
+	if owner := w.Owner("europe"); owner != "bob" {
+		t.Errorf("europe is held by %q, want bob", owner)
+	}
+	if got, want := w.Income("alice"), aliceIncome-3; got != want {
+		t.Errorf("alice's income = %d, want %d", got, want)
+	}
+	if got, want := w.Income("bob"), BaseIncome+3+3; got != want {
+		t.Errorf("bob's income = %d, want %d", got, want)
+	}
+}
+
+func TestHandleQuietPlayerUpdate(t *testing.T) {
+	gs := NewGameState("alice")
+	gs.HandlePlayerUpdate(PlayerUpdate{Player: Player{Username: "alice"}, Treasury: 14, Income: 4})
+	if gs.Treasury() != 14 || gs.Income() != 4 {
+		t.Errorf("treasury and income = %d and %d, want 14 and 4", gs.Treasury(), gs.Income())
+	}
+}
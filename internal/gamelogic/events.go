@@ -0,0 +1,176 @@
+package gamelogic
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+type EventKind string
+
+const (
+	EventSpawn       EventKind = "spawn"
+	EventMove        EventKind = "move"
+	EventWarDeclared EventKind = "war_declared"
+	EventWarResolved EventKind = "war_resolved"
+	EventPause       EventKind = "pause"
+	EventResume      EventKind = "resume"
+	EventRoundEnded  EventKind = "round_ended"
+	EventIncome      EventKind = "income"
+	EventGameOver    EventKind = "game_over"
+	EventLoad        EventKind = "load"
+	EventMap         EventKind = "map"
+)
+
+// Event is one thing that happened to the world, in the order the World
+// applied it. Units are the unit spawned, or the units moved to Location;
+// War is set for the war events, Round for the end of a round in turn mode,
+// Income for what each player was paid, GameOver for the end of the game,
+// World for the whole world after it was loaded from a save or when the
+// server started recording, and Map for the name of the map it started on.
+type Event struct {
+	Seq      uint64         `json:"seq"`
+	Time     time.Time      `json:"time"`
+	Kind     EventKind      `json:"kind"`
+	Player   string         `json:"player,omitempty"`
+	Units    []Unit         `json:"units,omitempty"`
+	Location Location       `json:"location,omitempty"`
+	War      *WarResult     `json:"war,omitempty"`
+	Round    int            `json:"round,omitempty"`
+	Income   map[string]int `json:"income,omitempty"`
+	GameOver *GameOver      `json:"game_over,omitempty"`
+	World    *worldSnapshot `json:"world,omitempty"`
+	Map      string         `json:"map,omitempty"`
+}
+
+func (e Event) String() string {
+	var what string
+	switch e.Kind {
+	case EventSpawn:
+		if len(e.Units) == 1 {
+			u := e.Units[0]
+			what = fmt.Sprintf("%s spawned a(n) %s in %s with id %v", e.Player, u.Rank, u.Location, u.ID)
+		} else {
+			what = fmt.Sprintf("%s spawned %v unit(s) in %s", e.Player, len(e.Units), e.Location)
+		}
+	case EventMove:
+		what = fmt.Sprintf("%s moved %v unit(s) to %s", e.Player, len(e.Units), e.Location)
+	case EventWarDeclared:
+		what = fmt.Sprintf("%s declared war on %s in %s", e.War.Attacker, e.War.Defender, e.War.Location)
+	case EventWarResolved:
+		if e.War.Winner == "" {
+			what = fmt.Sprintf("The war between %s and %s in %s ended in a draw", e.War.Attacker, e.War.Defender, e.War.Location)
+		} else {
+			what = fmt.Sprintf("%s won a war against %s in %s", e.War.Winner, e.War.Loser(), e.War.Location)
+		}
+	case EventPause:
+		what = "The game was paused"
+	case EventResume:
+		what = "The game was resumed"
+	case EventRoundEnded:
+		what = fmt.Sprintf("Round %d ended", e.Round)
+	case EventIncome:
+		total := 0
+		for _, gold := range e.Income {
+			total += gold
+		}
+		what = fmt.Sprintf("%d player(s) were paid %d gold in all", len(e.Income), total)
+	case EventGameOver:
+		what = "Game over. " + e.GameOver.Reason
+	case EventMap:
+		what = fmt.Sprintf("The server started on the %s map", e.Map)
+	case EventLoad:
+		what = fmt.Sprintf("The world was loaded from a save, with %d player(s)", len(e.World.Players))
+	default:
+		what = fmt.Sprintf("Unknown event %q", e.Kind)
+	}
+	return fmt.Sprintf("#%d %s %s", e.Seq, e.Time.Format(time.RFC3339), what)
+}
+
+// EventLogPath is where the server records the game's events.
+func EventLogPath() string {
+	return filepath.Join(snapshotDir, "events.jsonl")
+}
+
+// EventLog appends events to a file, one JSON object per line, numbering
+// them from 1 on.
+type EventLog struct {
+	mu   sync.Mutex
+	f    *os.File
+	last uint64
+}
+
+// OpenEventLog opens the log at path, creating it if needed, and carries on
+// numbering from its last event.
+func OpenEventLog(path string) (*EventLog, error) {
+	events, err := ReadEvents(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return nil, fmt.Errorf("could not create event log directory: %v", err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("could not open event log: %v", err)
+	}
+	l := &EventLog{f: f}
+	if len(events) > 0 {
+		l.last = events[len(events)-1].Seq
+	}
+	return l, nil
+}
+
+// Append numbers and timestamps e and writes it to the log.
+func (l *EventLog) Append(e Event) (Event, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e.Seq = l.last + 1
+	e.Time = time.Now()
+	data, err := json.Marshal(e)
+	if err != nil {
+		return e, err
+	}
+	_, err = l.f.Write(append(data, '\n'))
+	if err != nil {
+		return e, fmt.Errorf("could not write to event log: %v", err)
+	}
+	l.last = e.Seq
+	return e, nil
+}
+
+func (l *EventLog) Close() error {
+	return l.f.Close()
+}
+
+// ReadEvents reads every event in the log at path, in order.
+func ReadEvents(path string) ([]Event, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	events := []Event{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e Event
+		err = json.Unmarshal(scanner.Bytes(), &e)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		events = append(events, e)
+	}
+	return events, scanner.Err()
+}
@@ -25,13 +25,51 @@ type ArmyMove struct {
 	ToLocation Location
 }
 
-type RecognitionOfWar struct {
-	Attacker Player
-	Defender Player
+// WarResult is the server's verdict on a war. The units are each side's
+// units in Location when the war started. Winner is empty if the war was a
+// draw, in which case both sides lost their units.
+type WarResult struct {
+	Attacker      string
+	Defender      string
+	Location      Location
+	Winner        string
+	AttackerUnits []Unit
+	DefenderUnits []Unit
 }
 
 type Location string
 
+type Spawn struct {
+	Rank     UnitRank
+	Location Location
+}
+
+// Order is a command a client sends the server, which carries it out only if
+// it is valid. Exactly one of Spawn and Move is set.
+type Order struct {
+	Username string
+	Spawn    *Spawn
+	Move     *ArmyMove
+}
+
+// PlayerUpdate is the server's view of a player's army, sent to the player
+// whenever it changes or one of their orders is rejected.
+type PlayerUpdate struct {
+	Player Player
+	// Message is empty on updates nobody needs to be told about, such as
+	// income, which are applied without a word.
+	Message  string
+	Rejected bool
+	// Home is where the player spawns units, on maps with starting
+	// positions.
+	Home Location
+	// Treasury is the player's gold and Income what they earn each tick.
+	Treasury int
+	Income   int
+	// Territories are the ones the player controls.
+	Territories []Location
+}
+
 func getAllRanks() map[UnitRank]struct{} {
 	return map[UnitRank]struct{}{
 		RankInfantry:  {},
@@ -39,14 +77,3 @@ func getAllRanks() map[UnitRank]struct{} {
 		RankArtillery: {},
 	}
 }
-
-func getAllLocations() map[Location]struct{} {
-	return map[Location]struct{}{
-		"americas":   {},
-		"europe":     {},
-		"africa":     {},
-		"asia":       {},
-		"australia":  {},
-		"antarctica": {},
-	}
-}
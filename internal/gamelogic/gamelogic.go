@@ -2,11 +2,15 @@ package gamelogic
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"math/rand"
 	"os"
 	"strings"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
 )
 
 func PrintClientHelp() {
@@ -14,10 +18,13 @@ func PrintClientHelp() {
 	fmt.Println("* move <location> <unitID> <unitID> <unitID>...")
 	fmt.Println("    example:")
 	fmt.Println("    move asia 1")
+	fmt.Println("    units move one territory per move; see status for where they can go")
 	fmt.Println("* spawn <location> <rank>")
 	fmt.Println("    example:")
 	fmt.Println("    spawn europe infantry")
+	fmt.Printf("    units cost gold: infantry %d, cavalry %d, artillery %d\n", UnitCost(RankInfantry), UnitCost(RankCavalry), UnitCost(RankArtillery))
 	fmt.Println("* status")
+	fmt.Println("* save")
 	fmt.Println("* spam <n>")
 	fmt.Println("    example:")
 	fmt.Println("    spam 5")
@@ -33,6 +40,10 @@ func ClientWelcome() (string, error) {
 		return "", errors.New("you must enter a username. goodbye")
 	}
 	username := words[0]
+	err := routing.ValidateUsername(username)
+	if err != nil {
+		return "", err
+	}
 	fmt.Printf("Welcome, %s!\n", username)
 	PrintClientHelp()
 	return username, nil
@@ -42,6 +53,12 @@ func PrintServerHelp() {
 	fmt.Println("Possible commands:")
 	fmt.Println("* pause")
 	fmt.Println("* resume")
+	fmt.Println("* save")
+	fmt.Println("* load")
+	fmt.Println("* dlq list")
+	fmt.Println("* dlq show <n>")
+	fmt.Println("* dlq replay <n|all>")
+	fmt.Println("* dlq purge")
 	fmt.Println("* quit")
 	fmt.Println("* help")
 }
@@ -58,6 +75,25 @@ func GetInput() []string {
 	return strings.Fields(line)
 }
 
+// GetInputContext is GetInput that gives up when ctx is done. It returns
+// io.EOF once stdin is closed.
+func GetInputContext(ctx context.Context) ([]string, error) {
+	lines := make(chan []string, 1)
+	go func() {
+		lines <- GetInput()
+	}()
+	select {
+	case words := <-lines:
+		if words == nil {
+			return nil, io.EOF
+		}
+		return words, nil
+	case <-ctx.Done():
+		fmt.Println()
+		return nil, ctx.Err()
+	}
+}
+
 func GetMaliciousLog() string {
 	possibleLogs := []string{
 		"Never interrupt your enemy when he is making a mistake.",
@@ -72,11 +108,25 @@ func GetMaliciousLog() string {
 	return msg
 }
 
+func PrintReconnecting(attempt int, err error) {
+	fmt.Println()
+	fmt.Printf("Lost connection to the broker (%v), reconnecting… (attempt %d)\n", err, attempt)
+}
+
+func PrintReconnected() {
+	fmt.Println("Reconnected to the broker!")
+	fmt.Print("> ")
+}
+
 func PrintQuit() {
 	fmt.Println("I hate this game! (╯°□°)╯︵ ┻━┻")
 }
 
 func (gs *GameState) CommandStatus() {
+	if over := gs.gameOver(); over != nil {
+		fmt.Println("The game is over.")
+		fmt.Println(over)
+	}
 	if gs.isPaused() {
 		fmt.Println("The game is paused.")
 		return
@@ -85,8 +135,28 @@ func (gs *GameState) CommandStatus() {
 	}
 
 	p := gs.GetPlayerSnap()
+	m := gs.Map()
 	fmt.Printf("You are %s, and you have %d units.\n", p.Username, len(p.Units))
+	fmt.Printf("You are playing on the %s map.\n", m.Name)
+	if home := gs.Home(); home != "" {
+		fmt.Printf("Your home is %s.\n", home)
+	}
+	fmt.Printf("Your treasury has %d gold, and you earn %d each tick.\n", gs.Treasury(), gs.Income())
+	if territories := gs.Territories(); len(territories) > 0 {
+		fmt.Printf("You control %s.\n", joinLocations(territories))
+	}
 	for _, unit := range p.Units {
 		fmt.Printf("* %v: %v, %v\n", unit.ID, unit.Location, unit.Rank)
+		if t, ok := m.Territory(unit.Location); ok {
+			fmt.Printf("    can move to: %v\n", joinLocations(t.Adjacent))
+		}
+	}
+}
+
+func joinLocations(locs []Location) string {
+	names := make([]string, 0, len(locs))
+	for _, loc := range locs {
+		names = append(names, string(loc))
 	}
+	return strings.Join(names, ", ")
 }
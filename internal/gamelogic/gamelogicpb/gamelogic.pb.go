@@ -0,0 +1,1071 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.9
+// 	protoc        (unknown)
+// source: gamelogic.proto
+
+package gamelogicpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Ranks and locations are sent as the same lowercase strings the CLI uses,
+// e.g. "infantry" and "europe".
+type Unit struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Rank          string                 `protobuf:"bytes,2,opt,name=rank,proto3" json:"rank,omitempty"`
+	Location      string                 `protobuf:"bytes,3,opt,name=location,proto3" json:"location,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Unit) Reset() {
+	*x = Unit{}
+	mi := &file_gamelogic_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Unit) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Unit) ProtoMessage() {}
+
+func (x *Unit) ProtoReflect() protoreflect.Message {
+	mi := &file_gamelogic_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Unit.ProtoReflect.Descriptor instead.
+func (*Unit) Descriptor() ([]byte, []int) {
+	return file_gamelogic_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Unit) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Unit) GetRank() string {
+	if x != nil {
+		return x.Rank
+	}
+	return ""
+}
+
+func (x *Unit) GetLocation() string {
+	if x != nil {
+		return x.Location
+	}
+	return ""
+}
+
+type Player struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Username string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	// Keyed by unit ID.
+	Units         map[int64]*Unit `protobuf:"bytes,2,rep,name=units,proto3" json:"units,omitempty" protobuf_key:"varint,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Player) Reset() {
+	*x = Player{}
+	mi := &file_gamelogic_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Player) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Player) ProtoMessage() {}
+
+func (x *Player) ProtoReflect() protoreflect.Message {
+	mi := &file_gamelogic_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Player.ProtoReflect.Descriptor instead.
+func (*Player) Descriptor() ([]byte, []int) {
+	return file_gamelogic_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Player) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *Player) GetUnits() map[int64]*Unit {
+	if x != nil {
+		return x.Units
+	}
+	return nil
+}
+
+// Published to peril_topic with routing key army_moves.<username>.
+type ArmyMove struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Player        *Player                `protobuf:"bytes,1,opt,name=player,proto3" json:"player,omitempty"`
+	Units         []*Unit                `protobuf:"bytes,2,rep,name=units,proto3" json:"units,omitempty"`
+	ToLocation    string                 `protobuf:"bytes,3,opt,name=to_location,json=toLocation,proto3" json:"to_location,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ArmyMove) Reset() {
+	*x = ArmyMove{}
+	mi := &file_gamelogic_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ArmyMove) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ArmyMove) ProtoMessage() {}
+
+func (x *ArmyMove) ProtoReflect() protoreflect.Message {
+	mi := &file_gamelogic_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ArmyMove.ProtoReflect.Descriptor instead.
+func (*ArmyMove) Descriptor() ([]byte, []int) {
+	return file_gamelogic_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ArmyMove) GetPlayer() *Player {
+	if x != nil {
+		return x.Player
+	}
+	return nil
+}
+
+func (x *ArmyMove) GetUnits() []*Unit {
+	if x != nil {
+		return x.Units
+	}
+	return nil
+}
+
+func (x *ArmyMove) GetToLocation() string {
+	if x != nil {
+		return x.ToLocation
+	}
+	return ""
+}
+
+// The server's verdict on a war, published to peril_topic with routing key
+// war.<attacker>. The units are each side's units in the location when the
+// war started. The winner is empty on a draw.
+type WarResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Attacker      string                 `protobuf:"bytes,1,opt,name=attacker,proto3" json:"attacker,omitempty"`
+	Defender      string                 `protobuf:"bytes,2,opt,name=defender,proto3" json:"defender,omitempty"`
+	Location      string                 `protobuf:"bytes,3,opt,name=location,proto3" json:"location,omitempty"`
+	Winner        string                 `protobuf:"bytes,4,opt,name=winner,proto3" json:"winner,omitempty"`
+	AttackerUnits []*Unit                `protobuf:"bytes,5,rep,name=attacker_units,json=attackerUnits,proto3" json:"attacker_units,omitempty"`
+	DefenderUnits []*Unit                `protobuf:"bytes,6,rep,name=defender_units,json=defenderUnits,proto3" json:"defender_units,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WarResult) Reset() {
+	*x = WarResult{}
+	mi := &file_gamelogic_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WarResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WarResult) ProtoMessage() {}
+
+func (x *WarResult) ProtoReflect() protoreflect.Message {
+	mi := &file_gamelogic_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WarResult.ProtoReflect.Descriptor instead.
+func (*WarResult) Descriptor() ([]byte, []int) {
+	return file_gamelogic_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *WarResult) GetAttacker() string {
+	if x != nil {
+		return x.Attacker
+	}
+	return ""
+}
+
+func (x *WarResult) GetDefender() string {
+	if x != nil {
+		return x.Defender
+	}
+	return ""
+}
+
+func (x *WarResult) GetLocation() string {
+	if x != nil {
+		return x.Location
+	}
+	return ""
+}
+
+func (x *WarResult) GetWinner() string {
+	if x != nil {
+		return x.Winner
+	}
+	return ""
+}
+
+func (x *WarResult) GetAttackerUnits() []*Unit {
+	if x != nil {
+		return x.AttackerUnits
+	}
+	return nil
+}
+
+func (x *WarResult) GetDefenderUnits() []*Unit {
+	if x != nil {
+		return x.DefenderUnits
+	}
+	return nil
+}
+
+type Spawn struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Rank          string                 `protobuf:"bytes,1,opt,name=rank,proto3" json:"rank,omitempty"`
+	Location      string                 `protobuf:"bytes,2,opt,name=location,proto3" json:"location,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Spawn) Reset() {
+	*x = Spawn{}
+	mi := &file_gamelogic_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Spawn) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Spawn) ProtoMessage() {}
+
+func (x *Spawn) ProtoReflect() protoreflect.Message {
+	mi := &file_gamelogic_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Spawn.ProtoReflect.Descriptor instead.
+func (*Spawn) Descriptor() ([]byte, []int) {
+	return file_gamelogic_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Spawn) GetRank() string {
+	if x != nil {
+		return x.Rank
+	}
+	return ""
+}
+
+func (x *Spawn) GetLocation() string {
+	if x != nil {
+		return x.Location
+	}
+	return ""
+}
+
+// Published to peril_topic with routing key orders.<username>, for the
+// server to carry out.
+type Order struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Username string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	// Types that are valid to be assigned to Kind:
+	//
+	//	*Order_Spawn
+	//	*Order_Move
+	Kind          isOrder_Kind `protobuf_oneof:"kind"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Order) Reset() {
+	*x = Order{}
+	mi := &file_gamelogic_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Order) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Order) ProtoMessage() {}
+
+func (x *Order) ProtoReflect() protoreflect.Message {
+	mi := &file_gamelogic_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Order.ProtoReflect.Descriptor instead.
+func (*Order) Descriptor() ([]byte, []int) {
+	return file_gamelogic_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Order) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *Order) GetKind() isOrder_Kind {
+	if x != nil {
+		return x.Kind
+	}
+	return nil
+}
+
+func (x *Order) GetSpawn() *Spawn {
+	if x != nil {
+		if x, ok := x.Kind.(*Order_Spawn); ok {
+			return x.Spawn
+		}
+	}
+	return nil
+}
+
+func (x *Order) GetMove() *ArmyMove {
+	if x != nil {
+		if x, ok := x.Kind.(*Order_Move); ok {
+			return x.Move
+		}
+	}
+	return nil
+}
+
+type isOrder_Kind interface {
+	isOrder_Kind()
+}
+
+type Order_Spawn struct {
+	Spawn *Spawn `protobuf:"bytes,2,opt,name=spawn,proto3,oneof"`
+}
+
+type Order_Move struct {
+	Move *ArmyMove `protobuf:"bytes,3,opt,name=move,proto3,oneof"`
+}
+
+func (*Order_Spawn) isOrder_Kind() {}
+
+func (*Order_Move) isOrder_Kind() {}
+
+// Published by the server to peril_topic with routing key
+// player_updates.<username>.
+type PlayerUpdate struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Player   *Player                `protobuf:"bytes,1,opt,name=player,proto3" json:"player,omitempty"`
+	Message  string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	Rejected bool                   `protobuf:"varint,3,opt,name=rejected,proto3" json:"rejected,omitempty"`
+	// The only territory the player can spawn in, on maps with starting
+	// positions.
+	Home string `protobuf:"bytes,4,opt,name=home,proto3" json:"home,omitempty"`
+	// The player's gold, and what they earn each tick.
+	Treasury int64 `protobuf:"varint,5,opt,name=treasury,proto3" json:"treasury,omitempty"`
+	Income   int64 `protobuf:"varint,6,opt,name=income,proto3" json:"income,omitempty"`
+	// The territories the player controls.
+	Territories   []string `protobuf:"bytes,7,rep,name=territories,proto3" json:"territories,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PlayerUpdate) Reset() {
+	*x = PlayerUpdate{}
+	mi := &file_gamelogic_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PlayerUpdate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PlayerUpdate) ProtoMessage() {}
+
+func (x *PlayerUpdate) ProtoReflect() protoreflect.Message {
+	mi := &file_gamelogic_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PlayerUpdate.ProtoReflect.Descriptor instead.
+func (*PlayerUpdate) Descriptor() ([]byte, []int) {
+	return file_gamelogic_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *PlayerUpdate) GetPlayer() *Player {
+	if x != nil {
+		return x.Player
+	}
+	return nil
+}
+
+func (x *PlayerUpdate) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *PlayerUpdate) GetRejected() bool {
+	if x != nil {
+		return x.Rejected
+	}
+	return false
+}
+
+func (x *PlayerUpdate) GetHome() string {
+	if x != nil {
+		return x.Home
+	}
+	return ""
+}
+
+func (x *PlayerUpdate) GetTreasury() int64 {
+	if x != nil {
+		return x.Treasury
+	}
+	return 0
+}
+
+func (x *PlayerUpdate) GetIncome() int64 {
+	if x != nil {
+		return x.Income
+	}
+	return 0
+}
+
+func (x *PlayerUpdate) GetTerritories() []string {
+	if x != nil {
+		return x.Territories
+	}
+	return nil
+}
+
+type Territory struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Terrain       string                 `protobuf:"bytes,2,opt,name=terrain,proto3" json:"terrain,omitempty"`
+	Income        int64                  `protobuf:"varint,3,opt,name=income,proto3" json:"income,omitempty"`
+	Adjacent      []string               `protobuf:"bytes,4,rep,name=adjacent,proto3" json:"adjacent,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Territory) Reset() {
+	*x = Territory{}
+	mi := &file_gamelogic_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Territory) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Territory) ProtoMessage() {}
+
+func (x *Territory) ProtoReflect() protoreflect.Message {
+	mi := &file_gamelogic_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Territory.ProtoReflect.Descriptor instead.
+func (*Territory) Descriptor() ([]byte, []int) {
+	return file_gamelogic_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Territory) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Territory) GetTerrain() string {
+	if x != nil {
+		return x.Terrain
+	}
+	return ""
+}
+
+func (x *Territory) GetIncome() int64 {
+	if x != nil {
+		return x.Income
+	}
+	return 0
+}
+
+func (x *Territory) GetAdjacent() []string {
+	if x != nil {
+		return x.Adjacent
+	}
+	return nil
+}
+
+type Region struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Bonus         int64                  `protobuf:"varint,2,opt,name=bonus,proto3" json:"bonus,omitempty"`
+	Territories   []string               `protobuf:"bytes,3,rep,name=territories,proto3" json:"territories,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Region) Reset() {
+	*x = Region{}
+	mi := &file_gamelogic_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Region) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Region) ProtoMessage() {}
+
+func (x *Region) ProtoReflect() protoreflect.Message {
+	mi := &file_gamelogic_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Region.ProtoReflect.Descriptor instead.
+func (*Region) Descriptor() ([]byte, []int) {
+	return file_gamelogic_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Region) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Region) GetBonus() int64 {
+	if x != nil {
+		return x.Bonus
+	}
+	return 0
+}
+
+func (x *Region) GetTerritories() []string {
+	if x != nil {
+		return x.Territories
+	}
+	return nil
+}
+
+// The board the server plays on, sent to clients that ask for it with the
+// routing key map on peril_direct.
+type Map struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	Name              string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Territories       []*Territory           `protobuf:"bytes,2,rep,name=territories,proto3" json:"territories,omitempty"`
+	Regions           []*Region              `protobuf:"bytes,3,rep,name=regions,proto3" json:"regions,omitempty"`
+	StartingPositions []string               `protobuf:"bytes,4,rep,name=starting_positions,json=startingPositions,proto3" json:"starting_positions,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *Map) Reset() {
+	*x = Map{}
+	mi := &file_gamelogic_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Map) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Map) ProtoMessage() {}
+
+func (x *Map) ProtoReflect() protoreflect.Message {
+	mi := &file_gamelogic_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Map.ProtoReflect.Descriptor instead.
+func (*Map) Descriptor() ([]byte, []int) {
+	return file_gamelogic_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Map) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Map) GetTerritories() []*Territory {
+	if x != nil {
+		return x.Territories
+	}
+	return nil
+}
+
+func (x *Map) GetRegions() []*Region {
+	if x != nil {
+		return x.Regions
+	}
+	return nil
+}
+
+func (x *Map) GetStartingPositions() []string {
+	if x != nil {
+		return x.StartingPositions
+	}
+	return nil
+}
+
+// Published to peril_direct with routing key round at the start of each
+// round, when the server runs in turn mode.
+type Round struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Number        int64                  `protobuf:"varint,1,opt,name=number,proto3" json:"number,omitempty"`
+	Deadline      *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=deadline,proto3" json:"deadline,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Round) Reset() {
+	*x = Round{}
+	mi := &file_gamelogic_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Round) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Round) ProtoMessage() {}
+
+func (x *Round) ProtoReflect() protoreflect.Message {
+	mi := &file_gamelogic_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Round.ProtoReflect.Descriptor instead.
+func (*Round) Descriptor() ([]byte, []int) {
+	return file_gamelogic_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *Round) GetNumber() int64 {
+	if x != nil {
+		return x.Number
+	}
+	return 0
+}
+
+func (x *Round) GetDeadline() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Deadline
+	}
+	return nil
+}
+
+type Standing struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Player        string                 `protobuf:"bytes,1,opt,name=player,proto3" json:"player,omitempty"`
+	Territories   int64                  `protobuf:"varint,2,opt,name=territories,proto3" json:"territories,omitempty"`
+	Units         int64                  `protobuf:"varint,3,opt,name=units,proto3" json:"units,omitempty"`
+	Score         int64                  `protobuf:"varint,4,opt,name=score,proto3" json:"score,omitempty"`
+	Eliminated    bool                   `protobuf:"varint,5,opt,name=eliminated,proto3" json:"eliminated,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Standing) Reset() {
+	*x = Standing{}
+	mi := &file_gamelogic_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Standing) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Standing) ProtoMessage() {}
+
+func (x *Standing) ProtoReflect() protoreflect.Message {
+	mi := &file_gamelogic_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Standing.ProtoReflect.Descriptor instead.
+func (*Standing) Descriptor() ([]byte, []int) {
+	return file_gamelogic_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *Standing) GetPlayer() string {
+	if x != nil {
+		return x.Player
+	}
+	return ""
+}
+
+func (x *Standing) GetTerritories() int64 {
+	if x != nil {
+		return x.Territories
+	}
+	return 0
+}
+
+func (x *Standing) GetUnits() int64 {
+	if x != nil {
+		return x.Units
+	}
+	return 0
+}
+
+func (x *Standing) GetScore() int64 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+func (x *Standing) GetEliminated() bool {
+	if x != nil {
+		return x.Eliminated
+	}
+	return false
+}
+
+// Published to peril_direct with routing key game_over when a player wins,
+// or the game ends in a draw. standings are best first.
+type GameOver struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Winner        string                 `protobuf:"bytes,1,opt,name=winner,proto3" json:"winner,omitempty"`
+	Reason        string                 `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+	Standings     []*Standing            `protobuf:"bytes,3,rep,name=standings,proto3" json:"standings,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GameOver) Reset() {
+	*x = GameOver{}
+	mi := &file_gamelogic_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GameOver) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GameOver) ProtoMessage() {}
+
+func (x *GameOver) ProtoReflect() protoreflect.Message {
+	mi := &file_gamelogic_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GameOver.ProtoReflect.Descriptor instead.
+func (*GameOver) Descriptor() ([]byte, []int) {
+	return file_gamelogic_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *GameOver) GetWinner() string {
+	if x != nil {
+		return x.Winner
+	}
+	return ""
+}
+
+func (x *GameOver) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *GameOver) GetStandings() []*Standing {
+	if x != nil {
+		return x.Standings
+	}
+	return nil
+}
+
+var File_gamelogic_proto protoreflect.FileDescriptor
+
+const file_gamelogic_proto_rawDesc = "" +
+	"\n" +
+	"\x0fgamelogic.proto\x12\x0fperil.gamelogic\x1a\x1fgoogle/protobuf/timestamp.proto\"F\n" +
+	"\x04Unit\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x12\n" +
+	"\x04rank\x18\x02 \x01(\tR\x04rank\x12\x1a\n" +
+	"\blocation\x18\x03 \x01(\tR\blocation\"\xaf\x01\n" +
+	"\x06Player\x12\x1a\n" +
+	"\busername\x18\x01 \x01(\tR\busername\x128\n" +
+	"\x05units\x18\x02 \x03(\v2\".peril.gamelogic.Player.UnitsEntryR\x05units\x1aO\n" +
+	"\n" +
+	"UnitsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\x03R\x03key\x12+\n" +
+	"\x05value\x18\x02 \x01(\v2\x15.peril.gamelogic.UnitR\x05value:\x028\x01\"\x89\x01\n" +
+	"\bArmyMove\x12/\n" +
+	"\x06player\x18\x01 \x01(\v2\x17.peril.gamelogic.PlayerR\x06player\x12+\n" +
+	"\x05units\x18\x02 \x03(\v2\x15.peril.gamelogic.UnitR\x05units\x12\x1f\n" +
+	"\vto_location\x18\x03 \x01(\tR\n" +
+	"toLocation\"\xf3\x01\n" +
+	"\tWarResult\x12\x1a\n" +
+	"\battacker\x18\x01 \x01(\tR\battacker\x12\x1a\n" +
+	"\bdefender\x18\x02 \x01(\tR\bdefender\x12\x1a\n" +
+	"\blocation\x18\x03 \x01(\tR\blocation\x12\x16\n" +
+	"\x06winner\x18\x04 \x01(\tR\x06winner\x12<\n" +
+	"\x0eattacker_units\x18\x05 \x03(\v2\x15.peril.gamelogic.UnitR\rattackerUnits\x12<\n" +
+	"\x0edefender_units\x18\x06 \x03(\v2\x15.peril.gamelogic.UnitR\rdefenderUnits\"7\n" +
+	"\x05Spawn\x12\x12\n" +
+	"\x04rank\x18\x01 \x01(\tR\x04rank\x12\x1a\n" +
+	"\blocation\x18\x02 \x01(\tR\blocation\"\x8c\x01\n" +
+	"\x05Order\x12\x1a\n" +
+	"\busername\x18\x01 \x01(\tR\busername\x12.\n" +
+	"\x05spawn\x18\x02 \x01(\v2\x16.peril.gamelogic.SpawnH\x00R\x05spawn\x12/\n" +
+	"\x04move\x18\x03 \x01(\v2\x19.peril.gamelogic.ArmyMoveH\x00R\x04moveB\x06\n" +
+	"\x04kind\"\xdf\x01\n" +
+	"\fPlayerUpdate\x12/\n" +
+	"\x06player\x18\x01 \x01(\v2\x17.peril.gamelogic.PlayerR\x06player\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12\x1a\n" +
+	"\brejected\x18\x03 \x01(\bR\brejected\x12\x12\n" +
+	"\x04home\x18\x04 \x01(\tR\x04home\x12\x1a\n" +
+	"\btreasury\x18\x05 \x01(\x03R\btreasury\x12\x16\n" +
+	"\x06income\x18\x06 \x01(\x03R\x06income\x12 \n" +
+	"\vterritories\x18\a \x03(\tR\vterritories\"m\n" +
+	"\tTerritory\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x18\n" +
+	"\aterrain\x18\x02 \x01(\tR\aterrain\x12\x16\n" +
+	"\x06income\x18\x03 \x01(\x03R\x06income\x12\x1a\n" +
+	"\badjacent\x18\x04 \x03(\tR\badjacent\"T\n" +
+	"\x06Region\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
+	"\x05bonus\x18\x02 \x01(\x03R\x05bonus\x12 \n" +
+	"\vterritories\x18\x03 \x03(\tR\vterritories\"\xb9\x01\n" +
+	"\x03Map\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12<\n" +
+	"\vterritories\x18\x02 \x03(\v2\x1a.peril.gamelogic.TerritoryR\vterritories\x121\n" +
+	"\aregions\x18\x03 \x03(\v2\x17.peril.gamelogic.RegionR\aregions\x12-\n" +
+	"\x12starting_positions\x18\x04 \x03(\tR\x11startingPositions\"W\n" +
+	"\x05Round\x12\x16\n" +
+	"\x06number\x18\x01 \x01(\x03R\x06number\x126\n" +
+	"\bdeadline\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\bdeadline\"\x90\x01\n" +
+	"\bStanding\x12\x16\n" +
+	"\x06player\x18\x01 \x01(\tR\x06player\x12 \n" +
+	"\vterritories\x18\x02 \x01(\x03R\vterritories\x12\x14\n" +
+	"\x05units\x18\x03 \x01(\x03R\x05units\x12\x14\n" +
+	"\x05score\x18\x04 \x01(\x03R\x05score\x12\x1e\n" +
+	"\n" +
+	"eliminated\x18\x05 \x01(\bR\n" +
+	"eliminated\"s\n" +
+	"\bGameOver\x12\x16\n" +
+	"\x06winner\x18\x01 \x01(\tR\x06winner\x12\x16\n" +
+	"\x06reason\x18\x02 \x01(\tR\x06reason\x127\n" +
+	"\tstandings\x18\x03 \x03(\v2\x19.peril.gamelogic.StandingR\tstandingsBLZJgithub.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic/gamelogicpbb\x06proto3"
+
+var (
+	file_gamelogic_proto_rawDescOnce sync.Once
+	file_gamelogic_proto_rawDescData []byte
+)
+
+func file_gamelogic_proto_rawDescGZIP() []byte {
+	file_gamelogic_proto_rawDescOnce.Do(func() {
+		file_gamelogic_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_gamelogic_proto_rawDesc), len(file_gamelogic_proto_rawDesc)))
+	})
+	return file_gamelogic_proto_rawDescData
+}
+
+var file_gamelogic_proto_msgTypes = make([]protoimpl.MessageInfo, 14)
+var file_gamelogic_proto_goTypes = []any{
+	(*Unit)(nil),                  // 0: peril.gamelogic.Unit
+	(*Player)(nil),                // 1: peril.gamelogic.Player
+	(*ArmyMove)(nil),              // 2: peril.gamelogic.ArmyMove
+	(*WarResult)(nil),             // 3: peril.gamelogic.WarResult
+	(*Spawn)(nil),                 // 4: peril.gamelogic.Spawn
+	(*Order)(nil),                 // 5: peril.gamelogic.Order
+	(*PlayerUpdate)(nil),          // 6: peril.gamelogic.PlayerUpdate
+	(*Territory)(nil),             // 7: peril.gamelogic.Territory
+	(*Region)(nil),                // 8: peril.gamelogic.Region
+	(*Map)(nil),                   // 9: peril.gamelogic.Map
+	(*Round)(nil),                 // 10: peril.gamelogic.Round
+	(*Standing)(nil),              // 11: peril.gamelogic.Standing
+	(*GameOver)(nil),              // 12: peril.gamelogic.GameOver
+	nil,                           // 13: peril.gamelogic.Player.UnitsEntry
+	(*timestamppb.Timestamp)(nil), // 14: google.protobuf.Timestamp
+}
+var file_gamelogic_proto_depIdxs = []int32{
+	13, // 0: peril.gamelogic.Player.units:type_name -> peril.gamelogic.Player.UnitsEntry
+	1,  // 1: peril.gamelogic.ArmyMove.player:type_name -> peril.gamelogic.Player
+	0,  // 2: peril.gamelogic.ArmyMove.units:type_name -> peril.gamelogic.Unit
+	0,  // 3: peril.gamelogic.WarResult.attacker_units:type_name -> peril.gamelogic.Unit
+	0,  // 4: peril.gamelogic.WarResult.defender_units:type_name -> peril.gamelogic.Unit
+	4,  // 5: peril.gamelogic.Order.spawn:type_name -> peril.gamelogic.Spawn
+	2,  // 6: peril.gamelogic.Order.move:type_name -> peril.gamelogic.ArmyMove
+	1,  // 7: peril.gamelogic.PlayerUpdate.player:type_name -> peril.gamelogic.Player
+	7,  // 8: peril.gamelogic.Map.territories:type_name -> peril.gamelogic.Territory
+	8,  // 9: peril.gamelogic.Map.regions:type_name -> peril.gamelogic.Region
+	14, // 10: peril.gamelogic.Round.deadline:type_name -> google.protobuf.Timestamp
+	11, // 11: peril.gamelogic.GameOver.standings:type_name -> peril.gamelogic.Standing
+	0,  // 12: peril.gamelogic.Player.UnitsEntry.value:type_name -> peril.gamelogic.Unit
+	13, // [13:13] is the sub-list for method output_type
+	13, // [13:13] is the sub-list for method input_type
+	13, // [13:13] is the sub-list for extension type_name
+	13, // [13:13] is the sub-list for extension extendee
+	0,  // [0:13] is the sub-list for field type_name
+}
+
+func init() { file_gamelogic_proto_init() }
+func file_gamelogic_proto_init() {
+	if File_gamelogic_proto != nil {
+		return
+	}
+	file_gamelogic_proto_msgTypes[5].OneofWrappers = []any{
+		(*Order_Spawn)(nil),
+		(*Order_Move)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_gamelogic_proto_rawDesc), len(file_gamelogic_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   14,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_gamelogic_proto_goTypes,
+		DependencyIndexes: file_gamelogic_proto_depIdxs,
+		MessageInfos:      file_gamelogic_proto_msgTypes,
+	}.Build()
+	File_gamelogic_proto = out.File
+	file_gamelogic_proto_goTypes = nil
+	file_gamelogic_proto_depIdxs = nil
+}
@@ -1,6 +1,7 @@
 package gamelogic
 
 import (
+	"fmt"
 	"sync"
 )
 
@@ -8,6 +9,19 @@ type GameState struct {
 	Player Player
 	Paused bool
 	mu     *sync.RWMutex
+	// savePath is set by AutoSave.
+	savePath string
+	m        *Map
+	home     Location
+	// round is the latest round announced in turn mode.
+	round *Round
+	// treasury, income and territories are as of the server's last
+	// PlayerUpdate.
+	treasury    int
+	income      int
+	territories []Location
+	// over is set once the server announces the end of the game.
+	over *GameOver
 }
 
 func NewGameState(username string) *GameState {
@@ -16,18 +30,43 @@ func NewGameState(username string) *GameState {
 			Username: username,
 			Units:    map[int]Unit{},
 		},
-		Paused: false,
-		mu:     &sync.RWMutex{},
+		Paused:   false,
+		mu:       &sync.RWMutex{},
+		m:        DefaultMap(),
+		treasury: StartingTreasury,
+		income:   BaseIncome,
 	}
 }
 
+func (gs *GameState) Map() *Map {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return gs.m
+}
+
+// SetMap changes the board to the one the server plays on.
+func (gs *GameState) SetMap(m *Map) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.m = m
+}
+
+// Home is where the player can spawn units, or empty if anywhere.
+func (gs *GameState) Home() Location {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return gs.home
+}
+
 func (gs *GameState) resumeGame() {
+	defer gs.changed()
 	gs.mu.Lock()
 	defer gs.mu.Unlock()
 	gs.Paused = false
 }
 
 func (gs *GameState) pauseGame() {
+	defer gs.changed()
 	gs.mu.Lock()
 	defer gs.mu.Unlock()
 	gs.Paused = true
@@ -39,28 +78,37 @@ func (gs *GameState) isPaused() bool {
 	return gs.Paused
 }
 
-func (gs *GameState) addUnit(u Unit) {
-	gs.mu.Lock()
-	defer gs.mu.Unlock()
-	gs.Player.Units[u.ID] = u
+func (gs *GameState) isOver() bool {
+	return gs.gameOver() != nil
 }
 
-func (gs *GameState) removeUnitsInLocation(loc Location) {
+func (gs *GameState) gameOver() *GameOver {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return gs.over
+}
+
+// removeUnits removes units that are still where they were. A unit the
+// server has already removed, or that has moved since, is left alone.
+func (gs *GameState) removeUnits(units []Unit) {
+	defer gs.changed()
 	gs.mu.Lock()
 	defer gs.mu.Unlock()
-	for k, v := range gs.Player.Units {
-		if v.Location == loc {
-			delete(gs.Player.Units, k)
+	for _, u := range units {
+		if have, ok := gs.Player.Units[u.ID]; ok && have.Location == u.Location {
+			delete(gs.Player.Units, u.ID)
 		}
 	}
 }
 
 func (gs *GameState) UpdateUnit(u Unit) {
+	defer gs.changed()
 	gs.mu.Lock()
 	defer gs.mu.Unlock()
 	gs.Player.Units[u.ID] = u
 }
 
+// GetUsername needs no lock: the username never changes.
 func (gs *GameState) GetUsername() string {
 	return gs.Player.Username
 }
@@ -94,3 +142,30 @@ func (gs *GameState) GetPlayerSnap() Player {
 		Units:    Units,
 	}
 }
+
+// HandlePlayerUpdate replaces the player's army with the server's view of it.
+func (gs *GameState) HandlePlayerUpdate(pu PlayerUpdate) {
+	if pu.Message != "" {
+		fmt.Println()
+		if pu.Rejected {
+			fmt.Println("==== Order Rejected ====")
+		} else {
+			fmt.Println("==== Army Updated ====")
+		}
+		fmt.Println(pu.Message)
+		fmt.Println("------------------------")
+	}
+
+	defer gs.changed()
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	units := map[int]Unit{}
+	for id, u := range pu.Player.Units {
+		units[id] = u
+	}
+	gs.Player.Units = units
+	gs.home = pu.Home
+	gs.treasury = pu.Treasury
+	gs.income = pu.Income
+	gs.territories = pu.Territories
+}
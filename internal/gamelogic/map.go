@@ -0,0 +1,276 @@
+package gamelogic
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed maps/world.json
+var defaultMapJSON []byte
+
+var (
+	ErrInvalidMap = errors.New("invalid map")
+	ErrWrongMap   = errors.New("played on a different map")
+)
+
+// Territory is a Location on the Map. Units move one edge at a time, from a
+// territory to one of its Adjacent ones.
+type Territory struct {
+	Name     Location   `json:"name" yaml:"name"`
+	Terrain  string     `json:"terrain" yaml:"terrain"`
+	Income   int        `json:"income" yaml:"income"`
+	Adjacent []Location `json:"adjacent" yaml:"adjacent"`
+}
+
+// Region is a group of territories worth Bonus to whoever holds all of them.
+type Region struct {
+	Name        string     `json:"name" yaml:"name"`
+	Bonus       int        `json:"bonus" yaml:"bonus"`
+	Territories []Location `json:"territories" yaml:"territories"`
+}
+
+// Map is the board: its territories and the edges between them. Edges go
+// both ways, whichever side lists them. On maps with StartingPositions,
+// each player is given one as their home, in the order they join, and can
+// only spawn units there.
+type Map struct {
+	Name              string      `json:"name" yaml:"name"`
+	Territories       []Territory `json:"territories" yaml:"territories"`
+	Regions           []Region    `json:"regions,omitempty" yaml:"regions"`
+	StartingPositions []Location  `json:"starting_positions,omitempty" yaml:"starting_positions"`
+
+	index map[Location]int
+}
+
+var DefaultMap = sync.OnceValue(func() *Map {
+	m, err := ParseMap(defaultMapJSON)
+	if err != nil {
+		panic(err)
+	}
+	return m
+})
+
+// LoadMap reads a map file, as YAML if its extension is .yaml or .yml and as
+// JSON otherwise.
+func LoadMap(path string) (*Map, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := &Map{}
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, m)
+	default:
+		err = json.Unmarshal(data, m)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read map %s: %v", path, err)
+	}
+	err = m.build()
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ParseMap reads a map from JSON.
+func ParseMap(data []byte) (*Map, error) {
+	m := &Map{}
+	err := json.Unmarshal(data, m)
+	if err != nil {
+		return nil, fmt.Errorf("could not read map: %v", err)
+	}
+	err = m.build()
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// build indexes the territories, adds the reverse of every edge and checks
+// that the map is playable: every name is unique and known, every territory
+// can be reached from every other, and so can every region's territories
+// without leaving the region.
+func (m *Map) build() error {
+	errs := []error{}
+	if len(m.Territories) == 0 {
+		errs = append(errs, errors.New("it has no territories"))
+	}
+	m.index = make(map[Location]int, len(m.Territories))
+	for i, t := range m.Territories {
+		if t.Name == "" {
+			errs = append(errs, fmt.Errorf("territory %d has no name", i+1))
+			continue
+		}
+		if _, ok := m.index[t.Name]; ok {
+			errs = append(errs, fmt.Errorf("%s is listed twice", t.Name))
+			continue
+		}
+		m.index[t.Name] = i
+	}
+	for _, t := range m.Territories {
+		for _, adj := range t.Adjacent {
+			i, ok := m.index[adj]
+			if !ok {
+				errs = append(errs, fmt.Errorf("%s is next to unknown territory %s", t.Name, adj))
+				continue
+			}
+			if adj == t.Name {
+				errs = append(errs, fmt.Errorf("%s is next to itself", t.Name))
+				continue
+			}
+			if !slices.Contains(m.Territories[i].Adjacent, t.Name) {
+				m.Territories[i].Adjacent = append(m.Territories[i].Adjacent, t.Name)
+			}
+		}
+	}
+	if len(errs) == 0 {
+		if unreachable := m.unreachable(m.Locations()); len(unreachable) > 0 {
+			errs = append(errs, fmt.Errorf("%v cannot be reached from %s", unreachable, m.Territories[0].Name))
+		}
+	}
+
+	inRegion := map[Location]string{}
+	regionNames := map[string]bool{}
+	for _, r := range m.Regions {
+		if regionNames[r.Name] {
+			errs = append(errs, fmt.Errorf("region %s is listed twice", r.Name))
+		}
+		regionNames[r.Name] = true
+		if len(r.Territories) == 0 {
+			errs = append(errs, fmt.Errorf("region %s has no territories", r.Name))
+			continue
+		}
+		known := true
+		for _, loc := range r.Territories {
+			if !m.Has(loc) {
+				errs = append(errs, fmt.Errorf("region %s has unknown territory %s", r.Name, loc))
+				known = false
+				continue
+			}
+			if other, ok := inRegion[loc]; ok {
+				errs = append(errs, fmt.Errorf("%s is in both region %s and region %s", loc, other, r.Name))
+			}
+			inRegion[loc] = r.Name
+		}
+		if known {
+			if unreachable := m.unreachable(r.Territories); len(unreachable) > 0 {
+				errs = append(errs, fmt.Errorf("region %s: %v cannot be reached from %s within the region", r.Name, unreachable, r.Territories[0]))
+			}
+		}
+	}
+
+	for i, loc := range m.StartingPositions {
+		if !m.Has(loc) {
+			errs = append(errs, fmt.Errorf("starting position %s is not a territory", loc))
+		}
+		if slices.Contains(m.StartingPositions[:i], loc) {
+			errs = append(errs, fmt.Errorf("starting position %s is listed twice", loc))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%w %q: %w", ErrInvalidMap, m.Name, errors.Join(errs...))
+	}
+	return nil
+}
+
+// unreachable lists the territories in locs that cannot be reached from the
+// first of them without leaving locs.
+func (m *Map) unreachable(locs []Location) []Location {
+	if len(locs) == 0 {
+		return nil
+	}
+	seen := map[Location]bool{locs[0]: true}
+	queue := []Location{locs[0]}
+	for len(queue) > 0 {
+		t, _ := m.Territory(queue[0])
+		queue = queue[1:]
+		for _, next := range t.Adjacent {
+			if !seen[next] && slices.Contains(locs, next) {
+				seen[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	unreachable := []Location{}
+	for _, loc := range locs {
+		if !seen[loc] {
+			unreachable = append(unreachable, loc)
+		}
+	}
+	return unreachable
+}
+
+func (m *Map) Territory(loc Location) (Territory, bool) {
+	i, ok := m.index[loc]
+	if !ok {
+		return Territory{}, false
+	}
+	return m.Territories[i], true
+}
+
+func (m *Map) Has(loc Location) bool {
+	_, ok := m.index[loc]
+	return ok
+}
+
+// Adjacent reports whether a unit in from can move to to in one move.
+func (m *Map) Adjacent(from, to Location) bool {
+	t, ok := m.Territory(from)
+	return ok && slices.Contains(t.Adjacent, to)
+}
+
+// Locations lists the territories in the order the map file gives them.
+func (m *Map) Locations() []Location {
+	locs := make([]Location, 0, len(m.Territories))
+	for _, t := range m.Territories {
+		locs = append(locs, t.Name)
+	}
+	return locs
+}
+
+// Path is the shortest route from from to to, one territory per move, not
+// counting from. It is nil if to cannot be reached. Ties between equally
+// short routes are always broken the same way, so clients and the server
+// agree on the route.
+func (m *Map) Path(from, to Location) []Location {
+	if !m.Has(from) || !m.Has(to) {
+		return nil
+	}
+	if from == to {
+		return []Location{}
+	}
+	prev := map[Location]Location{from: ""}
+	queue := []Location{from}
+	for len(queue) > 0 {
+		loc := queue[0]
+		queue = queue[1:]
+		t, _ := m.Territory(loc)
+		for _, next := range t.Adjacent {
+			if _, seen := prev[next]; seen {
+				continue
+			}
+			prev[next] = loc
+			if next == to {
+				path := []Location{}
+				for at := to; at != from; at = prev[at] {
+					path = append(path, at)
+				}
+				slices.Reverse(path)
+				return path
+			}
+			queue = append(queue, next)
+		}
+	}
+	return nil
+}
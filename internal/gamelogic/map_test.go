@@ -0,0 +1,159 @@
+package gamelogic
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseMapInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want string
+	}{
+		{"empty", `{"name": "empty"}`, "it has no territories"},
+		{"unnamed", `{"territories": [{"name": "a"}, {}]}`, "territory 2 has no name"},
+		{"duplicate", `{"territories": [{"name": "a"}, {"name": "a"}]}`, "a is listed twice"},
+		{"unknown edge", `{"territories": [{"name": "a", "adjacent": ["b"]}]}`, "a is next to unknown territory b"},
+		{"self edge", `{"territories": [{"name": "a", "adjacent": ["a"]}]}`, "a is next to itself"},
+		{"unreachable", `{"territories": [{"name": "a", "adjacent": ["b"]}, {"name": "b"}, {"name": "c"}]}`, "[c] cannot be reached from a"},
+		{
+			"region unknown territory",
+			`{"territories": [{"name": "a"}], "regions": [{"name": "r", "territories": ["b"]}]}`,
+			"region r has unknown territory b",
+		},
+		{
+			"region empty",
+			`{"territories": [{"name": "a"}], "regions": [{"name": "r"}]}`,
+			"region r has no territories",
+		},
+		{
+			"region split",
+			`{"territories": [{"name": "a", "adjacent": ["b"]}, {"name": "b", "adjacent": ["c"]}, {"name": "c"}],
+			  "regions": [{"name": "r", "territories": ["a", "c"]}]}`,
+			"region r: [c] cannot be reached from a within the region",
+		},
+		{
+			"territory in two regions",
+			`{"territories": [{"name": "a"}],
+			  "regions": [{"name": "r", "territories": ["a"]}, {"name": "s", "territories": ["a"]}]}`,
+			"a is in both region r and region s",
+		},
+		{
+			"unknown starting position",
+			`{"territories": [{"name": "a"}], "starting_positions": ["b"]}`,
+			"starting position b is not a territory",
+		},
+		{
+			"duplicate starting position",
+			`{"territories": [{"name": "a"}], "starting_positions": ["a", "a"]}`,
+			"starting position a is listed twice",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseMap([]byte(tt.json))
+			if !errors.Is(err, ErrInvalidMap) {
+				t.Fatalf("got %v, want ErrInvalidMap", err)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %q does not mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadMapAddsReverseEdges(t *testing.T) {
+	m, err := LoadMap("maps/islands.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The file only lists north_bay -> north_cape and straits -> south_cape.
+	for _, edge := range [][2]Location{{"north_cape", "north_bay"}, {"south_cape", "straits"}} {
+		if !m.Adjacent(edge[0], edge[1]) || !m.Adjacent(edge[1], edge[0]) {
+			t.Errorf("%s and %s are not adjacent both ways", edge[0], edge[1])
+		}
+	}
+	if m.Adjacent("north_bay", "south_bay") {
+		t.Error("north_bay is adjacent to south_bay")
+	}
+}
+
+func TestDefaultMap(t *testing.T) {
+	m := DefaultMap()
+	if m.Name != "world" {
+		t.Errorf("default map is %q, want world", m.Name)
+	}
+	for _, loc := range m.Locations() {
+		if !m.Has(loc) {
+			t.Errorf("Locations lists %s, which the map does not have", loc)
+		}
+	}
+}
+
+func TestMapPath(t *testing.T) {
+	m, err := LoadMap("maps/islands.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		from, to Location
+		want     []Location
+	}{
+		{"north_bay", "north_bay", []Location{}},
+		{"north_bay", "north_cape", []Location{"north_cape"}},
+		{"north_bay", "south_bay", []Location{"north_cape", "straits", "south_cape", "south_bay"}},
+		{"south_bay", "north_bay", []Location{"south_cape", "straits", "north_cape", "north_bay"}},
+		{"north_bay", "atlantis", nil},
+		{"atlantis", "north_bay", nil},
+	}
+	for _, tt := range tests {
+		got := m.Path(tt.from, tt.to)
+		if !slices.Equal(got, tt.want) || (got == nil) != (tt.want == nil) {
+			t.Errorf("Path(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestMapPathUnreachable(t *testing.T) {
+	// build rejects disconnected maps, so make one by hand.
+	m := &Map{
+		Territories: []Territory{{Name: "a"}, {Name: "b"}},
+		index:       map[Location]int{"a": 0, "b": 1},
+	}
+	if path := m.Path("a", "b"); path != nil {
+		t.Errorf("Path across a gap = %v, want nil", path)
+	}
+}
+
+func TestWorldLoadChecksMap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "world.json")
+	w := NewWorld()
+	if _, err := w.Spawn("alice", Spawn{Rank: RankInfantry, Location: "europe"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	islands, err := LoadMap("maps/islands.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := NewWorld()
+	other.SetMap(islands)
+	if err := other.Load(path); !errors.Is(err, ErrWrongMap) {
+		t.Errorf("loading a world map save on islands: got %v, want ErrWrongMap", err)
+	}
+
+	same := NewWorld()
+	if err := same.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	if units := same.Player("alice").Units; len(units) != 1 {
+		t.Errorf("loaded %d units, want 1", len(units))
+	}
+}
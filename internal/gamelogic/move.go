@@ -50,15 +50,20 @@ func getOverlappingLocation(p1 Player, p2 Player) Location {
 }
 
 func (gs *GameState) CommandMove(words []string) (ArmyMove, error) {
+	if gs.isOver() {
+		return ArmyMove{}, errors.New("the game is over, you can not move units")
+	}
 	if gs.isPaused() {
 		return ArmyMove{}, errors.New("the game is paused, you can not move units")
 	}
 	if len(words) < 3 {
 		return ArmyMove{}, errors.New("usage: move <location> <unitID> <unitID> <unitID> etc")
 	}
+	if err := gs.checkTurnWindow(); err != nil {
+		return ArmyMove{}, err
+	}
 	newLocation := Location(words[1])
-	locations := getAllLocations()
-	if _, ok := locations[newLocation]; !ok {
+	if !gs.Map().Has(newLocation) {
 		return ArmyMove{}, fmt.Errorf("error: %s is not a valid location", newLocation)
 	}
 	unitIDs := []int{}
@@ -71,22 +76,60 @@ func (gs *GameState) CommandMove(words []string) (ArmyMove, error) {
 		unitIDs = append(unitIDs, unitID)
 	}
 
-	newUnits := []Unit{}
+	units := []Unit{}
 	for _, unitID := range unitIDs {
 		unit, ok := gs.GetUnit(unitID)
 		if !ok {
 			return ArmyMove{}, fmt.Errorf("error: unit with ID %v not found", unitID)
 		}
-		unit.Location = newLocation
-		gs.UpdateUnit(unit)
+		units = append(units, unit)
+	}
+
+	step, err := gs.nextStep(units, newLocation)
+	if err != nil {
+		return ArmyMove{}, err
+	}
+	newUnits := []Unit{}
+	for _, unit := range units {
+		unit.Location = step
 		newUnits = append(newUnits, unit)
 	}
 
+	// The units stay put until the server has carried out the move.
 	mv := ArmyMove{
-		ToLocation: newLocation,
+		ToLocation: step,
 		Units:      newUnits,
 		Player:     gs.GetPlayerSnap(),
 	}
-	fmt.Printf("Moved %v units to %s\n", len(mv.Units), mv.ToLocation)
+	fmt.Printf("Ordered %v units to %s\n", len(mv.Units), mv.ToLocation)
 	return mv, nil
 }
+
+// nextStep is where units go this move on their way to dest. Units move one
+// territory per move, so a group further away takes the first step of the
+// shortest path, and has to be moved again to get the rest of the way.
+func (gs *GameState) nextStep(units []Unit, dest Location) (Location, error) {
+	m := gs.Map()
+	far := false
+	for _, unit := range units {
+		if unit.Location != dest && !m.Adjacent(unit.Location, dest) {
+			far = true
+		}
+	}
+	if !far {
+		return dest, nil
+	}
+
+	from := units[0].Location
+	for _, unit := range units {
+		if unit.Location != from {
+			return "", fmt.Errorf("error: %s is more than one move away, and units %v and %v are in different places; move them separately", dest, units[0].ID, unit.ID)
+		}
+	}
+	path := m.Path(from, dest)
+	if path == nil {
+		return "", fmt.Errorf("error: %s cannot be reached from %s", dest, from)
+	}
+	fmt.Printf("%s is %d moves away from %s, heading to %s first. Move again to continue.\n", dest, len(path), from, path[0])
+	return path[0], nil
+}
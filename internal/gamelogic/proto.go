@@ -0,0 +1,320 @@
+package gamelogic
+
+import (
+	"fmt"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic/gamelogicpb"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+//go:generate protoc -I . --go_out=gamelogicpb --go_opt=paths=source_relative gamelogic.proto
+
+func unitToProto(u Unit) *gamelogicpb.Unit {
+	return &gamelogicpb.Unit{
+		Id:       int64(u.ID),
+		Rank:     string(u.Rank),
+		Location: string(u.Location),
+	}
+}
+
+func unitFromProto(pb *gamelogicpb.Unit) Unit {
+	return Unit{
+		ID:       int(pb.GetId()),
+		Rank:     UnitRank(pb.GetRank()),
+		Location: Location(pb.GetLocation()),
+	}
+}
+
+func playerToProto(p Player) *gamelogicpb.Player {
+	units := make(map[int64]*gamelogicpb.Unit, len(p.Units))
+	for id, u := range p.Units {
+		units[int64(id)] = unitToProto(u)
+	}
+	return &gamelogicpb.Player{
+		Username: p.Username,
+		Units:    units,
+	}
+}
+
+func playerFromProto(pb *gamelogicpb.Player) Player {
+	units := make(map[int]Unit, len(pb.GetUnits()))
+	for id, u := range pb.GetUnits() {
+		units[int(id)] = unitFromProto(u)
+	}
+	return Player{
+		Username: pb.GetUsername(),
+		Units:    units,
+	}
+}
+
+func (am ArmyMove) ToProto() proto.Message {
+	return &gamelogicpb.ArmyMove{
+		Player:     playerToProto(am.Player),
+		Units:      unitsToProto(am.Units),
+		ToLocation: string(am.ToLocation),
+	}
+}
+
+func (am *ArmyMove) NewProto() proto.Message {
+	return &gamelogicpb.ArmyMove{}
+}
+
+func (am *ArmyMove) FromProto(m proto.Message) error {
+	pb, ok := m.(*gamelogicpb.ArmyMove)
+	if !ok {
+		return fmt.Errorf("expected %T, got %T", pb, m)
+	}
+	am.Player = playerFromProto(pb.GetPlayer())
+	am.Units = unitsFromProto(pb.GetUnits())
+	am.ToLocation = Location(pb.GetToLocation())
+	return nil
+}
+
+func unitsToProto(units []Unit) []*gamelogicpb.Unit {
+	pbs := make([]*gamelogicpb.Unit, 0, len(units))
+	for _, u := range units {
+		pbs = append(pbs, unitToProto(u))
+	}
+	return pbs
+}
+
+func unitsFromProto(pbs []*gamelogicpb.Unit) []Unit {
+	units := make([]Unit, 0, len(pbs))
+	for _, u := range pbs {
+		units = append(units, unitFromProto(u))
+	}
+	return units
+}
+
+func (wr WarResult) ToProto() proto.Message {
+	return &gamelogicpb.WarResult{
+		Attacker:      wr.Attacker,
+		Defender:      wr.Defender,
+		Location:      string(wr.Location),
+		Winner:        wr.Winner,
+		AttackerUnits: unitsToProto(wr.AttackerUnits),
+		DefenderUnits: unitsToProto(wr.DefenderUnits),
+	}
+}
+
+func (wr *WarResult) NewProto() proto.Message {
+	return &gamelogicpb.WarResult{}
+}
+
+func (wr *WarResult) FromProto(m proto.Message) error {
+	pb, ok := m.(*gamelogicpb.WarResult)
+	if !ok {
+		return fmt.Errorf("expected %T, got %T", pb, m)
+	}
+	wr.Attacker = pb.GetAttacker()
+	wr.Defender = pb.GetDefender()
+	wr.Location = Location(pb.GetLocation())
+	wr.Winner = pb.GetWinner()
+	wr.AttackerUnits = unitsFromProto(pb.GetAttackerUnits())
+	wr.DefenderUnits = unitsFromProto(pb.GetDefenderUnits())
+	return nil
+}
+
+func (o Order) ToProto() proto.Message {
+	pb := &gamelogicpb.Order{Username: o.Username}
+	switch {
+	case o.Spawn != nil:
+		pb.Kind = &gamelogicpb.Order_Spawn{Spawn: &gamelogicpb.Spawn{
+			Rank:     string(o.Spawn.Rank),
+			Location: string(o.Spawn.Location),
+		}}
+	case o.Move != nil:
+		pb.Kind = &gamelogicpb.Order_Move{Move: o.Move.ToProto().(*gamelogicpb.ArmyMove)}
+	}
+	return pb
+}
+
+func (o *Order) NewProto() proto.Message {
+	return &gamelogicpb.Order{}
+}
+
+func (o *Order) FromProto(m proto.Message) error {
+	pb, ok := m.(*gamelogicpb.Order)
+	if !ok {
+		return fmt.Errorf("expected %T, got %T", pb, m)
+	}
+	*o = Order{Username: pb.GetUsername()}
+	switch kind := pb.GetKind().(type) {
+	case *gamelogicpb.Order_Spawn:
+		o.Spawn = &Spawn{
+			Rank:     UnitRank(kind.Spawn.GetRank()),
+			Location: Location(kind.Spawn.GetLocation()),
+		}
+	case *gamelogicpb.Order_Move:
+		o.Move = &ArmyMove{}
+		return o.Move.FromProto(kind.Move)
+	}
+	return nil
+}
+
+func (pu PlayerUpdate) ToProto() proto.Message {
+	return &gamelogicpb.PlayerUpdate{
+		Player:      playerToProto(pu.Player),
+		Message:     pu.Message,
+		Rejected:    pu.Rejected,
+		Home:        string(pu.Home),
+		Treasury:    int64(pu.Treasury),
+		Income:      int64(pu.Income),
+		Territories: locationsToProto(pu.Territories),
+	}
+}
+
+func (pu *PlayerUpdate) NewProto() proto.Message {
+	return &gamelogicpb.PlayerUpdate{}
+}
+
+func (pu *PlayerUpdate) FromProto(m proto.Message) error {
+	pb, ok := m.(*gamelogicpb.PlayerUpdate)
+	if !ok {
+		return fmt.Errorf("expected %T, got %T", pb, m)
+	}
+	pu.Player = playerFromProto(pb.GetPlayer())
+	pu.Message = pb.GetMessage()
+	pu.Rejected = pb.GetRejected()
+	pu.Home = Location(pb.GetHome())
+	pu.Treasury = int(pb.GetTreasury())
+	pu.Income = int(pb.GetIncome())
+	pu.Territories = locationsFromProto(pb.GetTerritories())
+	return nil
+}
+
+func locationsToProto(locs []Location) []string {
+	names := make([]string, 0, len(locs))
+	for _, loc := range locs {
+		names = append(names, string(loc))
+	}
+	return names
+}
+
+func locationsFromProto(names []string) []Location {
+	locs := make([]Location, 0, len(names))
+	for _, name := range names {
+		locs = append(locs, Location(name))
+	}
+	return locs
+}
+
+func (m Map) ToProto() proto.Message {
+	pb := &gamelogicpb.Map{
+		Name:              m.Name,
+		StartingPositions: locationsToProto(m.StartingPositions),
+	}
+	for _, t := range m.Territories {
+		pb.Territories = append(pb.Territories, &gamelogicpb.Territory{
+			Name:     string(t.Name),
+			Terrain:  t.Terrain,
+			Income:   int64(t.Income),
+			Adjacent: locationsToProto(t.Adjacent),
+		})
+	}
+	for _, r := range m.Regions {
+		pb.Regions = append(pb.Regions, &gamelogicpb.Region{
+			Name:        r.Name,
+			Bonus:       int64(r.Bonus),
+			Territories: locationsToProto(r.Territories),
+		})
+	}
+	return pb
+}
+
+func (m *Map) NewProto() proto.Message {
+	return &gamelogicpb.Map{}
+}
+
+// FromProto checks the map as LoadMap does, so a client never plays on a
+// board it cannot use.
+func (m *Map) FromProto(msg proto.Message) error {
+	pb, ok := msg.(*gamelogicpb.Map)
+	if !ok {
+		return fmt.Errorf("expected %T, got %T", pb, msg)
+	}
+	*m = Map{
+		Name:              pb.GetName(),
+		StartingPositions: locationsFromProto(pb.GetStartingPositions()),
+	}
+	for _, t := range pb.GetTerritories() {
+		m.Territories = append(m.Territories, Territory{
+			Name:     Location(t.GetName()),
+			Terrain:  t.GetTerrain(),
+			Income:   int(t.GetIncome()),
+			Adjacent: locationsFromProto(t.GetAdjacent()),
+		})
+	}
+	for _, r := range pb.GetRegions() {
+		m.Regions = append(m.Regions, Region{
+			Name:        r.GetName(),
+			Bonus:       int(r.GetBonus()),
+			Territories: locationsFromProto(r.GetTerritories()),
+		})
+	}
+	return m.build()
+}
+
+func (r Round) ToProto() proto.Message {
+	return &gamelogicpb.Round{
+		Number:   int64(r.Number),
+		Deadline: timestamppb.New(r.Deadline),
+	}
+}
+
+func (r *Round) NewProto() proto.Message {
+	return &gamelogicpb.Round{}
+}
+
+func (r *Round) FromProto(m proto.Message) error {
+	pb, ok := m.(*gamelogicpb.Round)
+	if !ok {
+		return fmt.Errorf("expected %T, got %T", pb, m)
+	}
+	r.Number = int(pb.GetNumber())
+	r.Deadline = pb.GetDeadline().AsTime()
+	return nil
+}
+
+func (g GameOver) ToProto() proto.Message {
+	standings := make([]*gamelogicpb.Standing, 0, len(g.Standings))
+	for _, s := range g.Standings {
+		standings = append(standings, &gamelogicpb.Standing{
+			Player:      s.Player,
+			Territories: int64(s.Territories),
+			Units:       int64(s.Units),
+			Score:       int64(s.Score),
+			Eliminated:  s.Eliminated,
+		})
+	}
+	return &gamelogicpb.GameOver{
+		Winner:    g.Winner,
+		Reason:    g.Reason,
+		Standings: standings,
+	}
+}
+
+func (g *GameOver) NewProto() proto.Message {
+	return &gamelogicpb.GameOver{}
+}
+
+func (g *GameOver) FromProto(m proto.Message) error {
+	pb, ok := m.(*gamelogicpb.GameOver)
+	if !ok {
+		return fmt.Errorf("expected %T, got %T", pb, m)
+	}
+	g.Winner = pb.GetWinner()
+	g.Reason = pb.GetReason()
+	g.Standings = make([]Standing, 0, len(pb.GetStandings()))
+	for _, s := range pb.GetStandings() {
+		g.Standings = append(g.Standings, Standing{
+			Player:      s.GetPlayer(),
+			Territories: int(s.GetTerritories()),
+			Units:       int(s.GetUnits()),
+			Score:       int(s.GetScore()),
+			Eliminated:  s.GetEliminated(),
+		})
+	}
+	return nil
+}
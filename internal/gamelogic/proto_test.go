@@ -0,0 +1,109 @@
+package gamelogic
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic/gamelogicpb"
+	"google.golang.org/protobuf/proto"
+)
+
+type protoMessage interface {
+	ToProto() proto.Message
+}
+
+type protoReceiver interface {
+	NewProto() proto.Message
+	FromProto(proto.Message) error
+}
+
+func TestProtoRoundTrip(t *testing.T) {
+	islands, err := LoadMap("maps/islands.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	units := []Unit{
+		{ID: 1, Rank: RankInfantry, Location: "europe"},
+		{ID: 2, Rank: RankArtillery, Location: "asia"},
+	}
+	player := Player{Username: "alice", Units: map[int]Unit{1: units[0], 2: units[1]}}
+	tests := []struct {
+		name string
+		in   protoMessage
+		out  protoReceiver
+	}{
+		{"army move", ArmyMove{Player: player, Units: units, ToLocation: "africa"}, &ArmyMove{}},
+		{"war result", WarResult{
+			Attacker:      "alice",
+			Defender:      "bob",
+			Location:      "asia",
+			Winner:        "bob",
+			AttackerUnits: units[:1],
+			DefenderUnits: units[1:],
+		}, &WarResult{}},
+		{"spawn order", spawnOrder("alice", RankCavalry, "europe"), &Order{}},
+		{"move order", Order{
+			Username: "alice",
+			Move:     &ArmyMove{Player: player, Units: units, ToLocation: "africa"},
+		}, &Order{}},
+		{"player update", PlayerUpdate{
+			Player:      player,
+			Message:     "Spawned infantry",
+			Rejected:    true,
+			Home:        "europe",
+			Treasury:    12,
+			Income:      3,
+			Territories: []Location{"asia", "europe"},
+		}, &PlayerUpdate{}},
+		// Islands has every part of a map: regions and starting positions.
+		{"map", *islands, &Map{}},
+		{"round", Round{Number: 4, Deadline: time.Date(2024, 5, 1, 12, 0, 30, 0, time.UTC)}, &Round{}},
+		{"game over", GameOver{
+			Winner: "alice",
+			Reason: "alice holds every territory",
+			Standings: []Standing{
+				{Player: "alice", Territories: 6, Units: 3, Score: 9},
+				{Player: "bob", Eliminated: true},
+			},
+		}, &GameOver{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := proto.Marshal(tt.in.ToProto())
+			if err != nil {
+				t.Fatal(err)
+			}
+			pb := tt.out.NewProto()
+			if err := proto.Unmarshal(data, pb); err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.out.FromProto(pb); err != nil {
+				t.Fatal(err)
+			}
+			got := reflect.ValueOf(tt.out).Elem().Interface()
+			if !reflect.DeepEqual(got, tt.in) {
+				t.Errorf("got %+v, want %+v", got, tt.in)
+			}
+		})
+	}
+}
+
+func TestMapFromProtoChecksMap(t *testing.T) {
+	pb := &gamelogicpb.Map{
+		Name:        "broken",
+		Territories: []*gamelogicpb.Territory{{Name: "a", Adjacent: []string{"b"}}},
+	}
+	var m Map
+	if err := m.FromProto(pb); !errors.Is(err, ErrInvalidMap) {
+		t.Errorf("got %v, want ErrInvalidMap", err)
+	}
+}
+
+func TestFromProtoWrongMessage(t *testing.T) {
+	var o Order
+	if err := o.FromProto(&gamelogicpb.Round{}); err == nil {
+		t.Error("decoding a round as an order did not fail")
+	}
+}
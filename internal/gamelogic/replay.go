@@ -0,0 +1,116 @@
+package gamelogic
+
+import "fmt"
+
+// Replay rebuilds the world from recorded events, one event at a time. It
+// applies what the events say happened rather than checking orders again,
+// so replaying the same events always gives the same world.
+type Replay struct {
+	world  *World
+	events []Event
+	next   int
+}
+
+// NewReplay replays events on m, which must be the map they were recorded
+// on.
+func NewReplay(m *Map, events []Event) (*Replay, error) {
+	world := NewWorld()
+	world.SetMap(m)
+	for _, e := range events {
+		if e.Kind == EventMap && e.Map != m.Name {
+			return nil, fmt.Errorf("%w: event #%d was recorded on the %s map, not the %s map", ErrWrongMap, e.Seq, e.Map, m.Name)
+		}
+		if e.World != nil {
+			err := world.checkMap(*e.World)
+			if err != nil {
+				return nil, fmt.Errorf("event #%d: %w", e.Seq, err)
+			}
+		}
+	}
+	return &Replay{
+		world:  world,
+		events: events,
+	}, nil
+}
+
+// Step applies the next event and returns it. It returns false once every
+// event has been applied.
+func (r *Replay) Step() (Event, bool) {
+	if r.next >= len(r.events) {
+		return Event{}, false
+	}
+	e := r.events[r.next]
+	r.next++
+	r.world.apply(e)
+	return e, true
+}
+
+// StepTo applies every event up to and including the one numbered seq.
+func (r *Replay) StepTo(seq uint64) {
+	for r.next < len(r.events) && r.events[r.next].Seq <= seq {
+		r.Step()
+	}
+}
+
+// Players lists everyone seen so far.
+func (r *Replay) Players() []string {
+	return r.world.Players()
+}
+
+// GameState is username's game as of the last event applied.
+func (r *Replay) GameState(username string) *GameState {
+	gs := NewGameState(username)
+	gs.Player = r.world.Player(username)
+	gs.SetMap(r.world.Map())
+	gs.Paused = r.world.Paused()
+	gs.treasury = r.world.Treasury(username)
+	gs.income = r.world.Income(username)
+	gs.territories = r.world.Territories(username)
+	gs.over = r.world.over
+	return gs
+}
+
+func (w *World) apply(e Event) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	switch e.Kind {
+	case EventSpawn, EventMove:
+		p := w.player(e.Player)
+		for _, u := range e.Units {
+			p.Units[u.ID] = u
+			if e.Kind == EventSpawn {
+				w.treasury[e.Player] -= UnitCost(u.Rank)
+			}
+		}
+	case EventWarDeclared:
+		w.player(e.War.Attacker)
+		w.player(e.War.Defender)
+	case EventWarResolved:
+		w.removeCasualties(*e.War)
+	case EventPause:
+		w.paused = true
+	case EventResume:
+		w.paused = false
+	case EventIncome:
+		for name, gold := range e.Income {
+			w.player(name)
+			w.treasury[name] += gold
+		}
+	case EventGameOver:
+		w.over = e.GameOver
+	case EventLoad:
+		// Logs outlive saves, so they may hold old ones.
+		snap := *e.World
+		snap.upgrade()
+		w.restore(snap)
+	case EventMap:
+		// Every server session starts from the world it loaded, which is
+		// empty for a new game: the log outlives the game that started it.
+		start := worldSnapshot{}
+		if e.World != nil {
+			start = *e.World
+		}
+		w.restore(start)
+	}
+	w.updateOwners()
+}
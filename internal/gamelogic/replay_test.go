@@ -0,0 +1,252 @@
+package gamelogic
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// recordWorld has w record to a new log in dir and returns a function that
+// closes the log and reads back what it recorded.
+func recordWorld(t *testing.T, w *World, dir string) func() []Event {
+	t.Helper()
+	path := filepath.Join(dir, "events.jsonl")
+	l, err := OpenEventLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.RecordTo(l)
+	return func() []Event {
+		t.Helper()
+		if err := l.Close(); err != nil {
+			t.Fatal(err)
+		}
+		events, err := ReadEvents(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return events
+	}
+}
+
+func replayAll(t *testing.T, m *Map, events []Event) *Replay {
+	t.Helper()
+	r, err := NewReplay(m, events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		if _, ok := r.Step(); !ok {
+			return r
+		}
+	}
+}
+
+// checkReplayMatches fails unless every player's game in r is the one they
+// have in w.
+func checkReplayMatches(t *testing.T, w *World, r *Replay) {
+	t.Helper()
+	if !reflect.DeepEqual(r.Players(), w.Players()) {
+		t.Fatalf("replay has players %v, want %v", r.Players(), w.Players())
+	}
+	for _, name := range w.Players() {
+		gs := r.GameState(name)
+		if !reflect.DeepEqual(gs.Player, w.Player(name)) {
+			t.Errorf("%s's army: replay has %v, want %v", name, gs.Player, w.Player(name))
+		}
+		if gs.Treasury() != w.Treasury(name) {
+			t.Errorf("%s's treasury: replay has %d, want %d", name, gs.Treasury(), w.Treasury(name))
+		}
+		if !reflect.DeepEqual(gs.Territories(), w.Territories(name)) {
+			t.Errorf("%s's territories: replay has %v, want %v", name, gs.Territories(), w.Territories(name))
+		}
+	}
+	if gs := r.GameState(""); gs.Paused != w.Paused() {
+		t.Errorf("replay paused = %v, want %v", gs.Paused, w.Paused())
+	}
+}
+
+func TestReplayMatchesLiveGame(t *testing.T) {
+	w := NewWorld()
+	events := recordWorld(t, w, t.TempDir())
+
+	if _, err := w.Spawn("alice", Spawn{Rank: RankCavalry, Location: "europe"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Spawn("bob", Spawn{Rank: RankInfantry, Location: "asia"}); err != nil {
+		t.Fatal(err)
+	}
+	w.PayIncome()
+	_, wars, err := w.Move("alice", ArmyMove{Units: []Unit{{ID: 1}}, ToLocation: "asia"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wars) != 1 || wars[0].Winner != "alice" {
+		t.Fatalf("wars = %v, want one won by alice", wars)
+	}
+	w.SetPaused(true)
+	// Rejected while paused, so not recorded.
+	if _, err := w.Spawn("carol", Spawn{Rank: RankInfantry, Location: "africa"}); !errors.Is(err, ErrGamePaused) {
+		t.Fatalf("spawn while paused: got %v, want ErrGamePaused", err)
+	}
+	w.SetPaused(false)
+	w.ResolveRound(1, []Order{
+		spawnOrder("bob", RankInfantry, "africa"),
+		moveOrder("alice", "africa", 1),
+	})
+	w.PayIncome()
+
+	recorded := events()
+	first := replayAll(t, w.Map(), recorded)
+	checkReplayMatches(t, w, first)
+
+	second := replayAll(t, w.Map(), recorded)
+	for _, name := range w.Players() {
+		if !reflect.DeepEqual(first.GameState(name).Player, second.GameState(name).Player) {
+			t.Errorf("replaying twice gave %s different armies", name)
+		}
+	}
+}
+
+func TestReplayStartsFromLoadedWorld(t *testing.T) {
+	dir := t.TempDir()
+	save := filepath.Join(dir, "world.json")
+	before := NewWorld()
+	if _, err := before.Spawn("alice", Spawn{Rank: RankArtillery, Location: "europe"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := before.Save(save); err != nil {
+		t.Fatal(err)
+	}
+
+	w := NewWorld()
+	events := recordWorld(t, w, dir)
+	if err := w.Load(save); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := w.Move("alice", ArmyMove{Units: []Unit{{ID: 1}}, ToLocation: "asia"}); err != nil {
+		t.Fatal(err)
+	}
+
+	checkReplayMatches(t, w, replayAll(t, w.Map(), events()))
+}
+
+func TestReplayStartsFromWorldLoadedBeforeRecording(t *testing.T) {
+	dir := t.TempDir()
+	save := filepath.Join(dir, "world.json")
+	before := NewWorld()
+	if _, err := before.Spawn("alice", Spawn{Rank: RankArtillery, Location: "europe"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := before.Save(save); err != nil {
+		t.Fatal(err)
+	}
+
+	// As the server starts: load, then record.
+	w := NewWorld()
+	if err := w.Load(save); err != nil {
+		t.Fatal(err)
+	}
+	events := recordWorld(t, w, dir)
+	if _, _, err := w.Move("alice", ArmyMove{Units: []Unit{{ID: 1}}, ToLocation: "asia"}); err != nil {
+		t.Fatal(err)
+	}
+
+	checkReplayMatches(t, w, replayAll(t, w.Map(), events()))
+}
+
+func TestReplayStartsEachSessionAfresh(t *testing.T) {
+	dir := t.TempDir()
+	save := filepath.Join(dir, "world.json")
+
+	first := NewWorld()
+	firstEvents := recordWorld(t, first, dir)
+	if _, err := first.Spawn("alice", Spawn{Rank: RankInfantry, Location: "europe"}); err != nil {
+		t.Fatal(err)
+	}
+	first.PayIncome()
+	if err := first.Save(save); err != nil {
+		t.Fatal(err)
+	}
+	firstEvents()
+
+	// The server restarts on the same game, which carries on from its save.
+	restarted := NewWorld()
+	if err := restarted.Load(save); err != nil {
+		t.Fatal(err)
+	}
+	restartedEvents := recordWorld(t, restarted, dir)
+	if _, err := restarted.Spawn("alice", Spawn{Rank: RankCavalry, Location: "europe"}); err != nil {
+		t.Fatal(err)
+	}
+	checkReplayMatches(t, restarted, replayAll(t, restarted.Map(), restartedEvents()))
+
+	// The save is deleted and a new game starts, recording to the same log.
+	fresh := NewWorld()
+	freshEvents := recordWorld(t, fresh, dir)
+	if _, err := fresh.Spawn("bob", Spawn{Rank: RankInfantry, Location: "asia"}); err != nil {
+		t.Fatal(err)
+	}
+	recorded := freshEvents()
+	checkReplayMatches(t, fresh, replayAll(t, fresh.Map(), recorded))
+
+	// Stepping through still shows the earlier sessions as they were.
+	r, err := NewReplay(fresh.Map(), recorded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions := 0
+	for _, e := range recorded {
+		if e.Kind == EventMap {
+			sessions++
+		}
+		if sessions == 3 {
+			r.StepTo(e.Seq - 1)
+			break
+		}
+	}
+	checkReplayMatches(t, restarted, r)
+}
+
+func TestStepTo(t *testing.T) {
+	w := NewWorld()
+	events := recordWorld(t, w, t.TempDir())
+	if _, err := w.Spawn("alice", Spawn{Rank: RankInfantry, Location: "europe"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := w.Move("alice", ArmyMove{Units: []Unit{{ID: 1}}, ToLocation: "asia"}); err != nil {
+		t.Fatal(err)
+	}
+
+	recorded := events()
+	r, err := NewReplay(w.Map(), recorded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Event 1 names the map and event 2 is the spawn.
+	r.StepTo(2)
+	if loc := r.GameState("alice").Player.Units[1].Location; loc != "europe" {
+		t.Errorf("after the spawn the unit is in %q, want europe", loc)
+	}
+	r.StepTo(recorded[len(recorded)-1].Seq)
+	if loc := r.GameState("alice").Player.Units[1].Location; loc != "asia" {
+		t.Errorf("after the move the unit is in %q, want asia", loc)
+	}
+}
+
+func TestReplayChecksMap(t *testing.T) {
+	w := NewWorld()
+	events := recordWorld(t, w, t.TempDir())
+	if _, err := w.Spawn("alice", Spawn{Rank: RankInfantry, Location: "europe"}); err != nil {
+		t.Fatal(err)
+	}
+
+	islands, err := LoadMap("maps/islands.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewReplay(islands, events()); !errors.Is(err, ErrWrongMap) {
+		t.Errorf("replaying on the wrong map: got %v, want ErrWrongMap", err)
+	}
+}
@@ -0,0 +1,305 @@
+package gamelogic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// snapshotVersion is bumped whenever the layout of a saved file changes.
+// Version 2 added the economy, territories, homes, the map and the end of
+// the game; saves from version 1 are upgraded when they are loaded.
+const snapshotVersion = 2
+
+const snapshotDir = "saves"
+
+var ErrSnapshotVersion = errors.New("unsupported snapshot version")
+
+type gameStateSnapshot struct {
+	Version     int        `json:"version"`
+	SavedAt     time.Time  `json:"saved_at"`
+	Player      Player     `json:"player"`
+	Paused      bool       `json:"paused"`
+	Treasury    int        `json:"treasury"`
+	Income      int        `json:"income"`
+	Territories []Location `json:"territories,omitempty"`
+	GameOver    *GameOver  `json:"game_over,omitempty"`
+}
+
+type worldSnapshot struct {
+	Version  int                 `json:"version"`
+	SavedAt  time.Time           `json:"saved_at"`
+	Players  map[string]Player   `json:"players"`
+	Homes    map[string]Location `json:"homes,omitempty"`
+	Treasury map[string]int      `json:"treasury,omitempty"`
+	Owners   map[Location]string `json:"owners,omitempty"`
+	Started  time.Time           `json:"started,omitempty"`
+	Streaks  map[string]int      `json:"streaks,omitempty"`
+	GameOver *GameOver           `json:"game_over,omitempty"`
+	Paused   bool                `json:"paused"`
+	// Map is the name of the map the world was saved on.
+	Map string `json:"map,omitempty"`
+}
+
+// SnapshotPath is where a player's client saves their game.
+func SnapshotPath(username string) string {
+	return filepath.Join(snapshotDir, "players", username+".json")
+}
+
+// WorldSnapshotPath is where the server saves the world.
+func WorldSnapshotPath() string {
+	return filepath.Join(snapshotDir, "world.json")
+}
+
+// AutoSave makes every change to the game state save it to path.
+func (gs *GameState) AutoSave(path string) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.savePath = path
+}
+
+// changed saves the game state if AutoSave is on. Callers must not hold the
+// lock.
+func (gs *GameState) changed() {
+	gs.mu.RLock()
+	path := gs.savePath
+	gs.mu.RUnlock()
+	if path == "" {
+		return
+	}
+	err := gs.Save(path)
+	if err != nil {
+		fmt.Printf("Could not save the game: %v\n", err)
+	}
+}
+
+func (gs *GameState) Save(path string) error {
+	gs.mu.RLock()
+	snap := gameStateSnapshot{
+		Version:     snapshotVersion,
+		SavedAt:     time.Now(),
+		Player:      copyPlayer(gs.Player),
+		Paused:      gs.Paused,
+		Treasury:    gs.treasury,
+		Income:      gs.income,
+		Territories: slices.Clone(gs.territories),
+		GameOver:    gs.over,
+	}
+	gs.mu.RUnlock()
+	return writeSnapshot(path, snap)
+}
+
+// Load replaces the game state with the one saved at path. The save must be
+// the same player's.
+func (gs *GameState) Load(path string) error {
+	var snap gameStateSnapshot
+	err := readSnapshot(path, &snap, &snap.Version)
+	if err != nil {
+		return err
+	}
+	if snap.Player.Username != gs.GetUsername() {
+		return fmt.Errorf("%s is %s's game, not %s's", path, snap.Player.Username, gs.GetUsername())
+	}
+	if snap.Player.Units == nil {
+		snap.Player.Units = map[int]Unit{}
+	}
+
+	gs.mu.Lock()
+	// Only the units: the username, which is the same, is read without the
+	// lock.
+	gs.Player.Units = snap.Player.Units
+	gs.Paused = snap.Paused
+	gs.treasury = snap.Treasury
+	gs.income = snap.Income
+	if snap.Version < 2 {
+		// Version 1 came before the economy.
+		gs.treasury = StartingTreasury
+		gs.income = BaseIncome
+	}
+	gs.territories = snap.Territories
+	gs.over = snap.GameOver
+	gs.mu.Unlock()
+	return nil
+}
+
+// AutoSave makes every change to the world save it to path.
+func (w *World) AutoSave(path string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.savePath = path
+}
+
+// changed saves the world if AutoSave is on. Callers must not hold the lock.
+func (w *World) changed() {
+	w.mu.Lock()
+	path := w.savePath
+	w.mu.Unlock()
+	if path == "" {
+		return
+	}
+	err := w.Save(path)
+	if err != nil {
+		fmt.Printf("Could not save the world: %v\n", err)
+	}
+}
+
+func (w *World) Save(path string) error {
+	w.mu.Lock()
+	snap := w.snapshot()
+	w.mu.Unlock()
+	return writeSnapshot(path, snap)
+}
+
+// Load replaces the world with the one saved at path, and records the
+// world it loaded so replays carry on from it.
+func (w *World) Load(path string) error {
+	var snap worldSnapshot
+	err := readSnapshot(path, &snap, &snap.Version)
+	if err != nil {
+		return err
+	}
+	snap.upgrade()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	err = w.checkMap(snap)
+	if err != nil {
+		return fmt.Errorf("could not load %s: %w", path, err)
+	}
+	w.restore(snap)
+	loaded := w.snapshot()
+	w.record(Event{Kind: EventLoad, World: &loaded})
+	return nil
+}
+
+func (w *World) snapshot() worldSnapshot {
+	snap := worldSnapshot{
+		Version:  snapshotVersion,
+		SavedAt:  time.Now(),
+		Players:  make(map[string]Player, len(w.players)),
+		Homes:    maps.Clone(w.homes),
+		Treasury: maps.Clone(w.treasury),
+		Owners:   maps.Clone(w.owners),
+		Started:  w.started,
+		Streaks:  maps.Clone(w.streaks),
+		GameOver: w.over,
+		Paused:   w.paused,
+		Map:      w.m.Name,
+	}
+	for name, p := range w.players {
+		snap.Players[name] = copyPlayer(p)
+	}
+	return snap
+}
+
+// upgrade brings a snapshot saved by an older version up to date.
+func (snap *worldSnapshot) upgrade() {
+	if snap.Version < 2 {
+		// Version 1 came before the economy. Ownership is worked out from
+		// the units by restore.
+		snap.Treasury = make(map[string]int, len(snap.Players))
+		for name := range snap.Players {
+			snap.Treasury[name] = StartingTreasury
+		}
+	}
+	snap.Version = snapshotVersion
+}
+
+// checkMap fails unless snap was saved on the world's map. Saves from
+// before maps were recorded only need their units to be on it.
+func (w *World) checkMap(snap worldSnapshot) error {
+	if snap.Map != "" && snap.Map != w.m.Name {
+		return fmt.Errorf("%w: it was saved on the %s map, not the %s map", ErrWrongMap, snap.Map, w.m.Name)
+	}
+	for name, p := range snap.Players {
+		for _, u := range p.Units {
+			if !w.m.Has(u.Location) {
+				return fmt.Errorf("%w: %s's unit %d is in %s, which is not on the %s map", ErrWrongMap, name, u.ID, u.Location, w.m.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// restore replaces the world with snap, which it does not keep any part of.
+func (w *World) restore(snap worldSnapshot) {
+	w.players = make(map[string]Player, len(snap.Players))
+	for name, p := range snap.Players {
+		p = copyPlayer(p)
+		p.Username = name
+		w.players[name] = p
+	}
+
+	w.homes = maps.Clone(snap.Homes)
+	if w.homes == nil {
+		w.homes = map[string]Location{}
+	}
+	w.owners = maps.Clone(snap.Owners)
+	if w.owners == nil {
+		w.owners = map[Location]string{}
+	}
+	w.streaks = maps.Clone(snap.Streaks)
+	if w.streaks == nil {
+		w.streaks = map[string]int{}
+	}
+
+	w.treasury = maps.Clone(snap.Treasury)
+	if w.treasury == nil {
+		w.treasury = map[string]int{}
+	}
+
+	w.started = snap.Started
+	w.over = snap.GameOver
+	w.paused = snap.Paused
+	w.updateOwners()
+}
+
+// writeSnapshot replaces path in one step, so a crash mid-write leaves the
+// previous save intact.
+func writeSnapshot(path string, snap any) error {
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return fmt.Errorf("could not create save directory: %v", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("could not create save file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not write save file: %v", err)
+	}
+	err = tmp.Close()
+	if err != nil {
+		return fmt.Errorf("could not write save file: %v", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// readSnapshot decodes path into snap and checks that the version read,
+// which points into snap, is one this build understands: the current one,
+// or an older one the caller upgrades.
+func readSnapshot(path string, snap any, version *int) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(data, snap)
+	if err != nil {
+		return fmt.Errorf("could not read %s: %v", path, err)
+	}
+	if *version < 1 || *version > snapshotVersion {
+		return fmt.Errorf("%w: %s is version %d, expected 1 to %d", ErrSnapshotVersion, path, *version, snapshotVersion)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package gamelogic
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestGameStateLoadWhileReading(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alice.json")
+	saved := NewGameState("alice")
+	saved.UpdateUnit(Unit{ID: 1, Rank: RankInfantry, Location: "europe"})
+	if err := saved.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	gs := NewGameState("alice")
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range 100 {
+			if name := gs.GetUsername(); name != "alice" {
+				t.Errorf("username is %q while loading", name)
+				return
+			}
+			gs.GetPlayerSnap()
+		}
+	}()
+	for range 100 {
+		if err := gs.Load(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+	if u, ok := gs.GetUnit(1); !ok || u.Location != "europe" {
+		t.Errorf("loaded unit 1 = %v, %v; want it in europe", u, ok)
+	}
+}
+
+func writeFile(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestGameStateLoadVersion1(t *testing.T) {
+	path := writeFile(t, "alice.json", `{
+		"version": 1,
+		"player": {"Username": "alice", "Units": {"1": {"ID": 1, "Rank": "infantry", "Location": "europe"}}},
+		"paused": true
+	}`)
+	gs := NewGameState("alice")
+	if err := gs.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	if gs.Treasury() != StartingTreasury || gs.Income() != BaseIncome {
+		t.Errorf("treasury and income = %d and %d, want %d and %d", gs.Treasury(), gs.Income(), StartingTreasury, BaseIncome)
+	}
+	if _, ok := gs.GetUnit(1); !ok || !gs.isPaused() {
+		t.Error("did not load the units and pause of a version 1 save")
+	}
+}
+
+func TestWorldLoadVersion1(t *testing.T) {
+	path := writeFile(t, "world.json", `{
+		"version": 1,
+		"players": {"alice": {"Username": "alice", "Units": {"1": {"ID": 1, "Rank": "infantry", "Location": "europe"}}}},
+		"paused": false
+	}`)
+	w := NewWorld()
+	if err := w.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	if gold := w.Treasury("alice"); gold != StartingTreasury {
+		t.Errorf("treasury = %d, want %d", gold, StartingTreasury)
+	}
+	if owner := w.Owner("europe"); owner != "alice" {
+		t.Errorf("europe is held by %q, want alice", owner)
+	}
+}
+
+func TestLoadUnknownVersion(t *testing.T) {
+	for _, version := range []int{0, snapshotVersion + 1} {
+		path := writeFile(t, "world.json", fmt.Sprintf(`{"version": %d}`, version))
+		if err := NewWorld().Load(path); !errors.Is(err, ErrSnapshotVersion) {
+			t.Errorf("world version %d: got %v, want ErrSnapshotVersion", version, err)
+		}
+		if err := NewGameState("alice").Load(path); !errors.Is(err, ErrSnapshotVersion) {
+			t.Errorf("client version %d: got %v, want ErrSnapshotVersion", version, err)
+		}
+	}
+}
+
+func TestWorldSaveRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "world.json")
+	w := NewWorld()
+	if _, err := w.Spawn("alice", Spawn{Rank: RankCavalry, Location: "europe"}); err != nil {
+		t.Fatal(err)
+	}
+	w.PayIncome()
+	if err := w.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := NewWorld()
+	if err := loaded.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := loaded.Treasury("alice"), w.Treasury("alice"); got != want {
+		t.Errorf("treasury = %d, want %d", got, want)
+	}
+	if got, want := loaded.Territories("alice"), w.Territories("alice"); !slices.Equal(got, want) {
+		t.Errorf("territories = %v, want %v", got, want)
+	}
+}
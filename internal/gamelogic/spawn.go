@@ -5,30 +5,43 @@ import (
 	"fmt"
 )
 
-func (gs *GameState) CommandSpawn(words []string) error {
+// CommandSpawn checks a spawn command and returns the order for it. The unit
+// only joins the army once the server has spawned it; see HandlePlayerUpdate.
+func (gs *GameState) CommandSpawn(words []string) (Spawn, error) {
+	if gs.isOver() {
+		return Spawn{}, errors.New("the game is over, you can not spawn units")
+	}
+	if gs.isPaused() {
+		return Spawn{}, errors.New("the game is paused, you can not spawn units")
+	}
 	if len(words) < 3 {
-		return errors.New("usage: spawn <location> <rank>")
+		return Spawn{}, errors.New("usage: spawn <location> <rank>")
+	}
+	if err := gs.checkTurnWindow(); err != nil {
+		return Spawn{}, err
 	}
 
 	locationName := words[1]
-	locations := getAllLocations()
-	if _, ok := locations[Location(locationName)]; !ok {
-		return fmt.Errorf("error: %s is not a valid location", locationName)
+	if !gs.Map().Has(Location(locationName)) {
+		return Spawn{}, fmt.Errorf("error: %s is not a valid location", locationName)
+	}
+	if home := gs.Home(); home != "" && Location(locationName) != home {
+		return Spawn{}, fmt.Errorf("error: you can only spawn units in your home, %s", home)
 	}
 
 	rank := words[2]
 	units := getAllRanks()
 	if _, ok := units[UnitRank(rank)]; !ok {
-		return fmt.Errorf("error: %s is not a valid unit", rank)
+		return Spawn{}, fmt.Errorf("error: %s is not a valid unit", rank)
+	}
+	// The server has the final say, but this saves a round trip.
+	if cost, gold := UnitCost(UnitRank(rank)), gs.Treasury(); gold < cost {
+		return Spawn{}, fmt.Errorf("error: a(n) %s costs %d gold and you have %d", rank, cost, gold)
 	}
 
-	id := len(gs.getUnitsSnap()) + 1
-	gs.addUnit(Unit{
-		ID:       id,
+	fmt.Printf("Requested a(n) %s in %s\n", rank, locationName)
+	return Spawn{
 		Rank:     UnitRank(rank),
 		Location: Location(locationName),
-	})
-
-	fmt.Printf("Spawned a(n) %s in %s with id %v\n", rank, locationName, id)
-	return nil
+	}, nil
 }
@@ -0,0 +1,117 @@
+package gamelogic
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+var ErrRoundClosed = errors.New("the round has closed")
+
+// Round is announced by the server at the start of each round in turn mode.
+// Orders that reach the server before Deadline are carried out together
+// when the round ends.
+type Round struct {
+	Number   int
+	Deadline time.Time
+}
+
+// OrderResult is what became of one order in a round: the unit it spawned
+// or the move it made, or Err if it was rejected.
+type OrderResult struct {
+	Order Order
+	Unit  Unit
+	Move  ArmyMove
+	Err   error
+}
+
+type RoundResult struct {
+	Number int
+	Orders []OrderResult
+	Wars   []WarResult
+}
+
+// ResolveRound carries out a round's orders as if they all happened at
+// once. The outcome depends only on the orders, not on when they arrived:
+// players go in username order, each with their orders in the order they
+// sent them, and every spawn comes before every move. Each unit can move
+// once a round. Wars are fought after everyone has moved, in every
+// territory someone moved into, in map order; the first player in username
+// order to have moved into a territory is the attacker there.
+func (w *World) ResolveRound(number int, orders []Order) RoundResult {
+	defer w.changed()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.record(Event{Kind: EventRoundEnded, Round: number})
+
+	sorted := slices.Clone(orders)
+	slices.SortStableFunc(sorted, func(a, b Order) int {
+		return strings.Compare(a.Username, b.Username)
+	})
+	result := RoundResult{Number: number}
+
+	for _, o := range sorted {
+		switch {
+		case o.Spawn != nil:
+			u, err := w.spawn(o.Username, *o.Spawn)
+			result.Orders = append(result.Orders, OrderResult{Order: o, Unit: u, Err: err})
+		case o.Move == nil:
+			result.Orders = append(result.Orders, OrderResult{Order: o, Err: errors.New("empty order")})
+		}
+	}
+
+	moved := map[string]map[int]bool{}
+	attackers := map[Location]string{}
+	for _, o := range sorted {
+		if o.Move == nil {
+			continue
+		}
+		if moved[o.Username] == nil {
+			moved[o.Username] = map[int]bool{}
+		}
+		done, err := w.move(o.Username, *o.Move, moved[o.Username])
+		result.Orders = append(result.Orders, OrderResult{Order: o, Move: done, Err: err})
+		if err != nil {
+			continue
+		}
+		for _, u := range done.Units {
+			moved[o.Username][u.ID] = true
+		}
+		if _, ok := attackers[done.ToLocation]; !ok {
+			attackers[done.ToLocation] = o.Username
+		}
+	}
+
+	for _, loc := range w.m.Locations() {
+		if attacker, ok := attackers[loc]; ok {
+			result.Wars = append(result.Wars, w.fight(attacker, loc)...)
+		}
+	}
+	w.updateOwners()
+	return result
+}
+
+// HandleRound starts the turn window for a round announced by the server.
+func (gs *GameState) HandleRound(r Round) {
+	defer fmt.Println("------------------------")
+	fmt.Println()
+	fmt.Printf("==== Round %d ====\n", r.Number)
+	fmt.Printf("Send your orders before %s.\n", r.Deadline.Format(time.TimeOnly))
+
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.round = &r
+}
+
+// checkTurnWindow fails if the game is in turn mode and the current round
+// no longer takes orders.
+func (gs *GameState) checkTurnWindow() error {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	if gs.round == nil || time.Now().Before(gs.round.Deadline) {
+		return nil
+	}
+	return fmt.Errorf("%w: round %d ended at %s, wait for the next one", ErrRoundClosed, gs.round.Number, gs.round.Deadline.Format(time.TimeOnly))
+}
@@ -0,0 +1,114 @@
+package gamelogic
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func spawnOrder(username string, rank UnitRank, loc Location) Order {
+	return Order{Username: username, Spawn: &Spawn{Rank: rank, Location: loc}}
+}
+
+func moveOrder(username string, to Location, ids ...int) Order {
+	units := make([]Unit, 0, len(ids))
+	for _, id := range ids {
+		units = append(units, Unit{ID: id})
+	}
+	return Order{Username: username, Move: &ArmyMove{Units: units, ToLocation: to}}
+}
+
+// newTurnWorld starts a world where alice has infantry 1 in europe and bob
+// has infantry 1 in asia.
+func newTurnWorld(t *testing.T) *World {
+	t.Helper()
+	w := NewWorld()
+	if _, err := w.Spawn("alice", Spawn{Rank: RankInfantry, Location: "europe"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Spawn("bob", Spawn{Rank: RankInfantry, Location: "asia"}); err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+func TestResolveRoundIgnoresArrivalOrder(t *testing.T) {
+	orders := []Order{
+		moveOrder("bob", "europe", 1),
+		spawnOrder("bob", RankInfantry, "asia"),
+		moveOrder("alice", "asia", 1),
+	}
+	reversed := []Order{orders[2], orders[1], orders[0]}
+
+	first, second := newTurnWorld(t), newTurnWorld(t)
+	got := first.ResolveRound(1, orders)
+	want := second.ResolveRound(1, reversed)
+	if !reflect.DeepEqual(got.Wars, want.Wars) {
+		t.Errorf("wars depend on arrival order:\n%v\n%v", got.Wars, want.Wars)
+	}
+	for _, name := range []string{"alice", "bob"} {
+		if !reflect.DeepEqual(first.Player(name), second.Player(name)) {
+			t.Errorf("%s's army depends on arrival order:\n%v\n%v", name, first.Player(name), second.Player(name))
+		}
+	}
+
+	// Bob left asia for europe, which alice left too, so the only war is
+	// alice's attack on bob's new unit in asia.
+	if len(got.Wars) != 1 {
+		t.Fatalf("got %d wars, want 1: %v", len(got.Wars), got.Wars)
+	}
+	war := got.Wars[0]
+	if war.Location != "asia" || war.Attacker != "alice" || war.Defender != "bob" {
+		t.Errorf("got %s attacking %s in %s, want alice attacking bob in asia", war.Attacker, war.Defender, war.Location)
+	}
+	if len(war.DefenderUnits) != 1 || war.DefenderUnits[0].ID != 2 {
+		t.Errorf("defenders = %v, want bob's new unit 2", war.DefenderUnits)
+	}
+	if owner := first.Owner("europe"); owner != "bob" {
+		t.Errorf("europe is held by %q, want bob", owner)
+	}
+}
+
+func TestResolveRoundSpawnsBeforeMoves(t *testing.T) {
+	w := newTurnWorld(t)
+	result := w.ResolveRound(1, []Order{
+		moveOrder("alice", "africa", 2),
+		spawnOrder("alice", RankCavalry, "europe"),
+	})
+	for _, o := range result.Orders {
+		if o.Err != nil {
+			t.Errorf("order %+v failed: %v", o.Order, o.Err)
+		}
+	}
+	if u := w.Player("alice").Units[2]; u.Location != "africa" {
+		t.Errorf("unit spawned this round is in %q, want africa", u.Location)
+	}
+}
+
+func TestResolveRoundMovesEachUnitOnce(t *testing.T) {
+	w := newTurnWorld(t)
+	result := w.ResolveRound(1, []Order{
+		moveOrder("alice", "africa", 1),
+		moveOrder("alice", "antarctica", 1),
+	})
+	if len(result.Orders) != 2 {
+		t.Fatalf("got %d order results, want 2", len(result.Orders))
+	}
+	if err := result.Orders[0].Err; err != nil {
+		t.Errorf("first move failed: %v", err)
+	}
+	if err := result.Orders[1].Err; !errors.Is(err, ErrAlreadyMoved) {
+		t.Errorf("second move: got %v, want ErrAlreadyMoved", err)
+	}
+	if u := w.Player("alice").Units[1]; u.Location != "africa" {
+		t.Errorf("unit is in %q, want africa", u.Location)
+	}
+}
+
+func TestResolveRoundRejectsEmptyOrders(t *testing.T) {
+	w := newTurnWorld(t)
+	result := w.ResolveRound(1, []Order{{Username: "alice"}})
+	if len(result.Orders) != 1 || result.Orders[0].Err == nil {
+		t.Errorf("empty order was not rejected: %+v", result.Orders)
+	}
+}
@@ -0,0 +1,227 @@
+package gamelogic
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+type VictoryKind string
+
+const (
+	// VictoryElimination ends the game when a single player has units or
+	// territories left.
+	VictoryElimination VictoryKind = "eliminate"
+	// VictoryHold ends the game when a player has held Territories
+	// territories for Rounds checks in a row.
+	VictoryHold VictoryKind = "hold"
+	// VictoryScore ends the game after TimeLimit, won by the highest score.
+	VictoryScore VictoryKind = "score"
+)
+
+// Victory is how a game is won. The World checks it once per income tick, or
+// once per round in turn mode. With any kind, a TimeLimit ends the game on
+// score if nobody has won by then.
+type Victory struct {
+	Kind        VictoryKind
+	Territories int
+	Rounds      int
+	TimeLimit   time.Duration
+}
+
+func (v Victory) Validate() error {
+	switch v.Kind {
+	case VictoryElimination:
+	case VictoryHold:
+		if v.Territories < 1 || v.Rounds < 1 {
+			return fmt.Errorf("holding needs at least 1 territory for at least 1 round, not %d for %d", v.Territories, v.Rounds)
+		}
+	case VictoryScore:
+		if v.TimeLimit <= 0 {
+			return errors.New("playing for score needs a time limit")
+		}
+	default:
+		return fmt.Errorf("unknown victory condition %q", v.Kind)
+	}
+	if v.TimeLimit < 0 {
+		return fmt.Errorf("time limit %v is negative", v.TimeLimit)
+	}
+	return nil
+}
+
+func (v Victory) String() string {
+	var s string
+	switch v.Kind {
+	case VictoryHold:
+		s = fmt.Sprintf("hold %d territories for %d rounds", v.Territories, v.Rounds)
+	case VictoryScore:
+		s = "highest score"
+	default:
+		s = "eliminate every opponent"
+	}
+	if v.TimeLimit > 0 {
+		s += fmt.Sprintf(", scored after %v", v.TimeLimit)
+	}
+	return s
+}
+
+// Standing is how a player finished. Score is their territories' income and
+// region bonuses plus the power of their units.
+type Standing struct {
+	Player      string
+	Territories int
+	Units       int
+	Score       int
+	Eliminated  bool
+}
+
+// GameOver is announced to every player when the game ends. Winner is empty
+// on a draw. Standings are best first.
+type GameOver struct {
+	Winner    string
+	Reason    string
+	Standings []Standing
+}
+
+func (g GameOver) String() string {
+	lines := []string{g.Reason}
+	for i, s := range g.Standings {
+		line := fmt.Sprintf("%d. %s: %d points, %d territories, %d units", i+1, s.Player, s.Score, s.Territories, s.Units)
+		if s.Eliminated {
+			line += " (eliminated)"
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// SetVictory sets how the game is won. The clock for its time limit starts
+// the first time it is set, and carries on across saves.
+func (w *World) SetVictory(v Victory) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.victory = v
+	if w.started.IsZero() {
+		w.started = time.Now()
+	}
+}
+
+// Over is the end of the game, if it has ended.
+func (w *World) Over() (GameOver, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.over == nil {
+		return GameOver{}, false
+	}
+	return *w.over, true
+}
+
+// CheckVictory ends the game if someone has won, returning how it ended. It
+// reports false if the game goes on, and once it has already ended. It does
+// nothing while the game is paused, though the time limit keeps running.
+func (w *World) CheckVictory() (GameOver, bool) {
+	defer w.changed()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.paused || w.over != nil || len(w.players) == 0 {
+		return GameOver{}, false
+	}
+	standings := w.standings()
+
+	var g *GameOver
+	switch w.victory.Kind {
+	case VictoryElimination:
+		alive := []string{}
+		for _, s := range standings {
+			if !s.Eliminated {
+				alive = append(alive, s.Player)
+			}
+		}
+		switch {
+		case len(standings) < 2:
+		case len(alive) == 1:
+			g = &GameOver{Winner: alive[0], Reason: fmt.Sprintf("%s eliminated every opponent", alive[0])}
+		case len(alive) == 0:
+			g = &GameOver{Reason: "Every player was eliminated"}
+		}
+	case VictoryHold:
+		for _, s := range standings {
+			if s.Territories >= w.victory.Territories {
+				w.streaks[s.Player]++
+			} else {
+				w.streaks[s.Player] = 0
+			}
+		}
+		// Standings are best first, so ties go to the higher score.
+		for _, s := range standings {
+			if w.streaks[s.Player] >= w.victory.Rounds {
+				g = &GameOver{Winner: s.Player, Reason: fmt.Sprintf("%s held %d territories for %d rounds", s.Player, w.victory.Territories, w.victory.Rounds)}
+				break
+			}
+		}
+	}
+	if g == nil && w.victory.TimeLimit > 0 && time.Since(w.started) >= w.victory.TimeLimit {
+		g = &GameOver{Reason: "Time is up: the game ended in a draw"}
+		if len(standings) == 1 || standings[0].Score > standings[1].Score {
+			g = &GameOver{Winner: standings[0].Player, Reason: fmt.Sprintf("Time is up: %s has the highest score", standings[0].Player)}
+		}
+	}
+	if g == nil {
+		return GameOver{}, false
+	}
+
+	g.Standings = standings
+	w.over = g
+	w.record(Event{Kind: EventGameOver, GameOver: g})
+	return *g, true
+}
+
+// standings ranks every player by score, then territories, then name.
+func (w *World) standings() []Standing {
+	standings := make([]Standing, 0, len(w.players))
+	for name, p := range w.players {
+		units := make([]Unit, 0, len(p.Units))
+		for _, u := range p.Units {
+			units = append(units, u)
+		}
+		standings = append(standings, Standing{
+			Player:      name,
+			Territories: len(w.controlled(name)),
+			Units:       len(units),
+			Score:       w.income(name) - BaseIncome + unitsToPowerLevel(units),
+			Eliminated:  w.eliminated(name),
+		})
+	}
+	slices.SortFunc(standings, func(a, b Standing) int {
+		return cmp.Or(
+			cmp.Compare(b.Score, a.Score),
+			cmp.Compare(b.Territories, a.Territories),
+			strings.Compare(a.Player, b.Player),
+		)
+	})
+	return standings
+}
+
+// HandleGameOver shows how the game ended and stops taking commands that
+// change the army.
+func (gs *GameState) HandleGameOver(g GameOver) {
+	defer fmt.Println("------------------------")
+	fmt.Println()
+	fmt.Println("==== Game Over ====")
+	switch g.Winner {
+	case "":
+	case gs.GetUsername():
+		fmt.Println("You won!")
+	default:
+		fmt.Printf("%s won.\n", g.Winner)
+	}
+	fmt.Println(g)
+
+	defer gs.changed()
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.over = &g
+}
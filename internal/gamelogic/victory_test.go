@@ -0,0 +1,153 @@
+package gamelogic
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCheckVictoryElimination(t *testing.T) {
+	w := newTurnWorld(t)
+	w.SetVictory(Victory{Kind: VictoryElimination})
+	if g, ok := w.CheckVictory(); ok {
+		t.Fatalf("game ended with both players alive: %v", g)
+	}
+
+	if _, err := w.Spawn("alice", Spawn{Rank: RankCavalry, Location: "europe"}); err != nil {
+		t.Fatal(err)
+	}
+	// Bob loses the only unit, and asia with it.
+	if _, _, err := w.Move("alice", ArmyMove{Units: []Unit{{ID: 2}}, ToLocation: "asia"}); err != nil {
+		t.Fatal(err)
+	}
+	g, ok := w.CheckVictory()
+	if !ok || g.Winner != "alice" {
+		t.Fatalf("got %v, %v, want alice to win", g, ok)
+	}
+	if last := g.Standings[len(g.Standings)-1]; last.Player != "bob" || !last.Eliminated {
+		t.Errorf("last standing is %+v, want bob eliminated", last)
+	}
+	if over, ok := w.Over(); !ok || !reflect.DeepEqual(over, g) {
+		t.Errorf("Over() = %v, %v, want %v", over, ok, g)
+	}
+	if _, ok := w.CheckVictory(); ok {
+		t.Error("the game ended twice")
+	}
+}
+
+func TestCheckVictoryNeedsOpponents(t *testing.T) {
+	w := NewWorld()
+	w.SetVictory(Victory{Kind: VictoryElimination})
+	if g, ok := w.CheckVictory(); ok {
+		t.Errorf("game with no players ended: %v", g)
+	}
+	if _, err := w.Spawn("alice", Spawn{Rank: RankInfantry, Location: "europe"}); err != nil {
+		t.Fatal(err)
+	}
+	if g, ok := w.CheckVictory(); ok {
+		t.Errorf("game with one player ended: %v", g)
+	}
+}
+
+func TestCheckVictoryHold(t *testing.T) {
+	w := newTurnWorld(t)
+	w.SetVictory(Victory{Kind: VictoryHold, Territories: 3, Rounds: 2})
+	move := func(user string, to Location) {
+		t.Helper()
+		if _, _, err := w.Move(user, ArmyMove{Units: []Unit{{ID: 1}}, ToLocation: to}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check := func(want bool) {
+		t.Helper()
+		g, ok := w.CheckVictory()
+		if ok != want {
+			t.Fatalf("game over = %v, want %v: %v", ok, want, g)
+		}
+		if ok && g.Winner != "alice" {
+			t.Errorf("winner = %q, want alice", g.Winner)
+		}
+	}
+
+	move("alice", "africa")
+	move("alice", "americas")
+	check(false)
+	// Bob takes europe, so alice's streak starts again.
+	move("bob", "europe")
+	check(false)
+	move("alice", "antarctica")
+	check(false)
+	// Paused checks do not count.
+	w.SetPaused(true)
+	check(false)
+	w.SetPaused(false)
+	check(true)
+}
+
+func TestCheckVictoryTimeLimit(t *testing.T) {
+	tests := []struct {
+		name   string
+		setup  func(w *World)
+		winner string
+	}{
+		{"draw", nil, ""},
+		{"highest score", func(w *World) {
+			if _, err := w.Spawn("bob", Spawn{Rank: RankInfantry, Location: "asia"}); err != nil {
+				t.Fatal(err)
+			}
+		}, "bob"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newTurnWorld(t)
+			if tt.setup != nil {
+				tt.setup(w)
+			}
+			// Hold cannot be won here, so only the time limit ends the game.
+			w.SetVictory(Victory{Kind: VictoryHold, Territories: 6, Rounds: 1, TimeLimit: time.Hour})
+			if g, ok := w.CheckVictory(); ok {
+				t.Fatalf("game ended before the time limit: %v", g)
+			}
+
+			w.started = time.Now().Add(-time.Hour)
+			g, ok := w.CheckVictory()
+			if !ok || g.Winner != tt.winner {
+				t.Errorf("got %v, %v, want %q to win", g, ok, tt.winner)
+			}
+		})
+	}
+}
+
+func TestStandingsTieBreaks(t *testing.T) {
+	w := NewWorld()
+	// Alice scores 3 for europe and 1 for the infantry.
+	if _, err := w.Spawn("alice", Spawn{Rank: RankInfantry, Location: "europe"}); err != nil {
+		t.Fatal(err)
+	}
+	// Bob scores the same with two territories, so ranks first.
+	if _, err := w.Spawn("bob", Spawn{Rank: RankInfantry, Location: "australia"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := w.Move("bob", ArmyMove{Units: []Unit{{ID: 1}}, ToLocation: "antarctica"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Spawn("bob", Spawn{Rank: RankInfantry, Location: "australia"}); err != nil {
+		t.Fatal(err)
+	}
+	// Carol is level with alice on both, so they go by name.
+	if _, err := w.Spawn("carol", Spawn{Rank: RankInfantry, Location: "asia"}); err != nil {
+		t.Fatal(err)
+	}
+
+	w.mu.Lock()
+	standings := w.standings()
+	w.mu.Unlock()
+	want := []Standing{
+		{Player: "bob", Territories: 2, Units: 2, Score: 4},
+		{Player: "alice", Territories: 1, Units: 1, Score: 4},
+		{Player: "carol", Territories: 1, Units: 1, Score: 4},
+	}
+	if !reflect.DeepEqual(standings, want) {
+		t.Errorf("standings = %+v, want %+v", standings, want)
+	}
+}
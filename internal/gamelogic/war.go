@@ -8,84 +8,70 @@ type WarOutcome int
 
 const (
 	WarOutcomeNotInvolved WarOutcome = iota
-	WarOutcomeNoUnits
 	WarOutcomeYouWon
 	WarOutcomeOpponentWon
 	WarOutcomeDraw
 )
 
-func (gs *GameState) HandleWar(rw RecognitionOfWar) (outcome WarOutcome, winner string, loser string) {
+// HandleWar shows the server's verdict on a war. Both sides get the same
+// verdict, and each removes its own casualties: the loser's units that
+// fought, or both sides' on a draw. It may arrive after a PlayerUpdate that
+// already left them out.
+func (gs *GameState) HandleWar(wr WarResult) WarOutcome {
 	defer fmt.Println("------------------------")
 	fmt.Println()
 	fmt.Println("==== War Declared ====")
-	fmt.Printf("%s has declared war on %s!\n", rw.Attacker.Username, rw.Defender.Username)
+	fmt.Printf("%s has declared war on %s in %s!\n", wr.Attacker, wr.Defender, wr.Location)
 
-	player := gs.GetPlayerSnap()
-
-	if player.Username == rw.Defender.Username {
-		fmt.Printf("%s, you published the war.\n", player.Username)
-		return WarOutcomeNotInvolved, "", ""
+	fmt.Printf("%s's units:\n", wr.Attacker)
+	for _, unit := range wr.AttackerUnits {
+		fmt.Printf("  * %v\n", unit.Rank)
 	}
-
-	if player.Username != rw.Attacker.Username {
-		fmt.Printf("%s, you are not involved in this war.\n", player.Username)
-		return WarOutcomeNotInvolved, "", ""
+	fmt.Printf("%s's units:\n", wr.Defender)
+	for _, unit := range wr.DefenderUnits {
+		fmt.Printf("  * %v\n", unit.Rank)
 	}
+	fmt.Printf("Attacker has a power level of %v\n", unitsToPowerLevel(wr.AttackerUnits))
+	fmt.Printf("Defender has a power level of %v\n", unitsToPowerLevel(wr.DefenderUnits))
 
-	overlappingLocation := getOverlappingLocation(rw.Attacker, rw.Defender)
-	if overlappingLocation == "" {
-		fmt.Printf("Error! No units are in the same location. No war will be fought.\n")
-		return WarOutcomeNoUnits, "", ""
+	if wr.Winner == "" {
+		fmt.Println("The war ended in a draw!")
+	} else {
+		fmt.Printf("%s has won the war!\n", wr.Winner)
 	}
 
-	attackerUnits := []Unit{}
-	defenderUnits := []Unit{}
-	for _, unit := range rw.Attacker.Units {
-		if unit.Location == overlappingLocation {
-			attackerUnits = append(attackerUnits, unit)
-		}
-	}
-	for _, unit := range rw.Defender.Units {
-		if unit.Location == overlappingLocation {
-			defenderUnits = append(defenderUnits, unit)
-		}
+	username := gs.GetUsername()
+	switch {
+	case username != wr.Attacker && username != wr.Defender:
+		fmt.Printf("%s, you are not involved in this war.\n", username)
+		return WarOutcomeNotInvolved
+	case wr.Winner == username:
+		fmt.Println("You have won the war!")
+		return WarOutcomeYouWon
 	}
-
-	fmt.Printf("%s's units:\n", rw.Attacker.Username)
-	for _, unit := range attackerUnits {
-		fmt.Printf("  * %v\n", unit.Rank)
+	outcome := WarOutcomeDraw
+	if wr.Winner != "" {
+		fmt.Println("You have lost the war!")
+		outcome = WarOutcomeOpponentWon
 	}
-	fmt.Printf("%s's units:\n", rw.Defender.Username)
-	for _, unit := range defenderUnits {
-		fmt.Printf("  * %v\n", unit.Rank)
+	if username == wr.Attacker {
+		gs.removeUnits(wr.AttackerUnits)
+	} else {
+		gs.removeUnits(wr.DefenderUnits)
 	}
-	attackerPower := unitsToPowerLevel(attackerUnits)
-	defenderPower := unitsToPowerLevel(defenderUnits)
-	fmt.Printf("Attacker has a power level of %v\n", attackerPower)
-	fmt.Printf("Defender has a power level of %v\n", defenderPower)
-	if attackerPower > defenderPower {
-		fmt.Printf("%s has won the war!\n", rw.Attacker.Username)
-		if player.Username == rw.Defender.Username {
-			fmt.Println("You have lost the war!")
-			gs.removeUnitsInLocation(overlappingLocation)
-			fmt.Printf("Your units in %s have been killed.\n", overlappingLocation)
-			return WarOutcomeOpponentWon, rw.Attacker.Username, rw.Defender.Username
-		}
-		return WarOutcomeYouWon, rw.Attacker.Username, rw.Defender.Username
-	} else if defenderPower > attackerPower {
-		fmt.Printf("%s has won the war!\n", rw.Defender.Username)
-		if player.Username == rw.Attacker.Username {
-			fmt.Println("You have lost the war!")
-			gs.removeUnitsInLocation(overlappingLocation)
-			fmt.Printf("Your units in %s have been killed.\n", overlappingLocation)
-			return WarOutcomeOpponentWon, rw.Defender.Username, rw.Attacker.Username
-		}
-		return WarOutcomeYouWon, rw.Defender.Username, rw.Attacker.Username
+	fmt.Printf("Your units in %s have been killed.\n", wr.Location)
+	return outcome
+}
+
+// Loser is the player who lost the war, or empty on a draw.
+func (wr WarResult) Loser() string {
+	switch wr.Winner {
+	case wr.Attacker:
+		return wr.Defender
+	case wr.Defender:
+		return wr.Attacker
 	}
-	fmt.Println("The war ended in a draw!")
-	fmt.Printf("Your units in %s have been killed.\n", overlappingLocation)
-	gs.removeUnitsInLocation(overlappingLocation)
-	return WarOutcomeDraw, rw.Attacker.Username, rw.Defender.Username
+	return ""
 }
 
 func unitsToPowerLevel(units []Unit) int {
@@ -0,0 +1,153 @@
+package gamelogic
+
+import (
+	"testing"
+)
+
+func TestWorldFight(t *testing.T) {
+	tests := []struct {
+		name     string
+		attacker UnitRank
+		defender UnitRank
+		winner   string
+	}{
+		{"attacker wins", RankCavalry, RankInfantry, "alice"},
+		{"defender wins", RankInfantry, RankCavalry, "bob"},
+		{"tie", RankInfantry, RankInfantry, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWorld()
+			if _, err := w.Spawn("alice", Spawn{Rank: tt.attacker, Location: "europe"}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := w.Spawn("bob", Spawn{Rank: tt.defender, Location: "asia"}); err != nil {
+				t.Fatal(err)
+			}
+			// Bob's unit in australia is not in the war.
+			if _, err := w.Spawn("bob", Spawn{Rank: RankInfantry, Location: "australia"}); err != nil {
+				t.Fatal(err)
+			}
+
+			_, wars, err := w.Move("alice", ArmyMove{Units: []Unit{{ID: 1}}, ToLocation: "asia"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(wars) != 1 {
+				t.Fatalf("got %d wars, want 1: %v", len(wars), wars)
+			}
+			wr := wars[0]
+			if wr.Attacker != "alice" || wr.Defender != "bob" || wr.Location != "asia" || wr.Winner != tt.winner {
+				t.Errorf("got %s attacking %s in %s won by %q, want alice attacking bob in asia won by %q", wr.Attacker, wr.Defender, wr.Location, wr.Winner, tt.winner)
+			}
+
+			_, aliceAlive := w.Player("alice").Units[1]
+			_, bobAlive := w.Player("bob").Units[1]
+			if want := tt.winner == "alice"; aliceAlive != want {
+				t.Errorf("alice's unit survived = %v, want %v", aliceAlive, want)
+			}
+			if want := tt.winner == "bob"; bobAlive != want {
+				t.Errorf("bob's unit survived = %v, want %v", bobAlive, want)
+			}
+			if _, ok := w.Player("bob").Units[2]; !ok {
+				t.Error("bob lost the unit in australia")
+			}
+
+			// A draw leaves asia with bob, who held it before.
+			owner := tt.winner
+			if owner == "" {
+				owner = "bob"
+			}
+			if got := w.Owner("asia"); got != owner {
+				t.Errorf("asia is held by %q, want %s", got, owner)
+			}
+		})
+	}
+}
+
+func TestWorldFightsDefendersInTurn(t *testing.T) {
+	w := NewWorld()
+	if _, err := w.Spawn("alice", Spawn{Rank: RankCavalry, Location: "europe"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"carol", "bob", "dave"} {
+		if _, err := w.Spawn(name, Spawn{Rank: RankInfantry, Location: "asia"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Carol's artillery beats alice, so dave is never attacked.
+	if _, err := w.Spawn("carol", Spawn{Rank: RankArtillery, Location: "asia"}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, wars, err := w.Move("alice", ArmyMove{Units: []Unit{{ID: 1}}, ToLocation: "asia"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wars) != 2 {
+		t.Fatalf("got %d wars, want 2: %v", len(wars), wars)
+	}
+	if wars[0].Defender != "bob" || wars[0].Winner != "alice" {
+		t.Errorf("first war against %s was won by %q, want against bob won by alice", wars[0].Defender, wars[0].Winner)
+	}
+	if wars[1].Defender != "carol" || wars[1].Winner != "carol" {
+		t.Errorf("second war against %s was won by %q, want against carol won by carol", wars[1].Defender, wars[1].Winner)
+	}
+	if units := w.Player("dave").Units; len(units) != 1 {
+		t.Errorf("dave has %d units, want 1", len(units))
+	}
+}
+
+func TestHandleWarViews(t *testing.T) {
+	tests := []struct {
+		name     string
+		winner   string
+		loser    string
+		attacker WarOutcome
+		defender WarOutcome
+	}{
+		{"attacker wins", "alice", "bob", WarOutcomeYouWon, WarOutcomeOpponentWon},
+		{"defender wins", "bob", "alice", WarOutcomeOpponentWon, WarOutcomeYouWon},
+		{"draw", "", "", WarOutcomeDraw, WarOutcomeDraw},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wr := WarResult{
+				Attacker:      "alice",
+				Defender:      "bob",
+				Location:      "asia",
+				Winner:        tt.winner,
+				AttackerUnits: []Unit{{ID: 1, Rank: RankInfantry, Location: "asia"}},
+				DefenderUnits: []Unit{{ID: 1, Rank: RankInfantry, Location: "asia"}},
+			}
+			views := []struct {
+				username string
+				want     WarOutcome
+			}{
+				{"alice", tt.attacker},
+				{"bob", tt.defender},
+				{"carol", WarOutcomeNotInvolved},
+			}
+			for _, v := range views {
+				gs := NewGameState(v.username)
+				gs.UpdateUnit(Unit{ID: 1, Rank: RankInfantry, Location: "asia"})
+				// Units elsewhere never fought.
+				gs.UpdateUnit(Unit{ID: 2, Rank: RankInfantry, Location: "europe"})
+
+				if got := gs.HandleWar(wr); got != v.want {
+					t.Errorf("%s's outcome = %v, want %v", v.username, got, v.want)
+				}
+				lost := v.want == WarOutcomeOpponentWon || v.want == WarOutcomeDraw
+				if _, ok := gs.GetUnit(1); ok == lost {
+					t.Errorf("%s's unit in asia survived = %v, want %v", v.username, ok, !lost)
+				}
+				if _, ok := gs.GetUnit(2); !ok {
+					t.Errorf("%s lost their unit in europe", v.username)
+				}
+			}
+			if loser := wr.Loser(); loser != tt.loser {
+				t.Errorf("loser = %q, want %q", loser, tt.loser)
+			}
+		})
+	}
+}
@@ -0,0 +1,373 @@
+package gamelogic
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+var (
+	ErrGamePaused      = errors.New("the game is paused")
+	ErrInvalidLocation = errors.New("invalid location")
+	ErrInvalidRank     = errors.New("invalid unit rank")
+	ErrUnitNotFound    = errors.New("unit not found")
+	ErrNoUnits         = errors.New("no units to move")
+	ErrNotAdjacent     = errors.New("location is not adjacent")
+	ErrNotHome         = errors.New("units can only be spawned at home")
+	ErrAlreadyMoved    = errors.New("unit has already moved this round")
+	ErrGameOver        = errors.New("the game is over")
+)
+
+// World is the server's model of every player's army. Clients send the
+// server Orders and apply the PlayerUpdates it sends back, so the World is
+// the source of truth for who has which units where.
+type World struct {
+	mu      sync.Mutex
+	players map[string]Player
+	paused  bool
+	// savePath is set by AutoSave.
+	savePath string
+	events   *EventLog
+	m        *Map
+	homes    map[string]Location
+	treasury map[string]int
+	owners   map[Location]string
+	victory  Victory
+	// started is when the clock for the victory's time limit started.
+	started time.Time
+	// streaks counts the checks in a row each player has held enough
+	// territories for VictoryHold.
+	streaks map[string]int
+	over    *GameOver
+}
+
+func NewWorld() *World {
+	return &World{
+		players:  map[string]Player{},
+		m:        DefaultMap(),
+		homes:    map[string]Location{},
+		treasury: map[string]int{},
+		owners:   map[Location]string{},
+		victory:  Victory{Kind: VictoryElimination},
+		streaks:  map[string]int{},
+	}
+}
+
+// SetMap changes the board. Call it before the game starts: units already
+// on the old board are not moved.
+func (w *World) SetMap(m *Map) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.m = m
+}
+
+func (w *World) Map() *Map {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.m
+}
+
+// Home is where username spawns units, given out from the map's starting
+// positions in the order players first spawn. Until username has, it is the
+// one they would be given next. It is empty on maps without them.
+func (w *World) Home(username string) Location {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.home(username)
+}
+
+func (w *World) home(username string) Location {
+	if _, ok := w.players[username]; ok {
+		return w.homes[username]
+	}
+	positions := w.m.StartingPositions
+	if len(positions) == 0 {
+		return ""
+	}
+	// More players than positions share them.
+	return positions[len(w.homes)%len(positions)]
+}
+
+// RecordTo makes the World append every change it makes to l, in the order
+// it makes them, starting with the name of its map and the world as it is.
+// Call it after SetMap and Load, so a save made on another map is refused
+// before anything is recorded.
+func (w *World) RecordTo(l *EventLog) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.events = l
+	// Replays need the board the game is played on, and the world the
+	// server started from.
+	start := w.snapshot()
+	w.record(Event{Kind: EventMap, Map: w.m.Name, World: &start})
+}
+
+func (w *World) record(e Event) {
+	if w.events == nil {
+		return
+	}
+	_, err := w.events.Append(e)
+	if err != nil {
+		fmt.Printf("Could not record %s: %v\n", e.Kind, err)
+	}
+}
+
+func (w *World) SetPaused(paused bool) {
+	defer w.changed()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.paused == paused {
+		return
+	}
+	w.paused = paused
+	if paused {
+		w.record(Event{Kind: EventPause})
+	} else {
+		w.record(Event{Kind: EventResume})
+	}
+}
+
+func (w *World) Paused() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.paused
+}
+
+// Players lists the usernames of everyone the World has seen, in order.
+func (w *World) Players() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	names := make([]string, 0, len(w.players))
+	for name := range w.players {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Player returns a copy of username's army. Players the World has not seen
+// have no units.
+func (w *World) Player(username string) Player {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	p, ok := w.players[username]
+	if !ok {
+		return Player{Username: username, Units: map[int]Unit{}}
+	}
+	return copyPlayer(p)
+}
+
+// player returns username's army, adding them to the world if they are new.
+// Only changes call it, so that asking about a player does not make them
+// one.
+func (w *World) player(username string) Player {
+	p, ok := w.players[username]
+	if !ok {
+		if home := w.home(username); home != "" {
+			w.homes[username] = home
+		}
+		p = Player{Username: username, Units: map[int]Unit{}}
+		w.players[username] = p
+		w.treasury[username] = StartingTreasury
+	}
+	return p
+}
+
+// Spawn adds a unit to username's army and returns it with the ID the World
+// gave it.
+func (w *World) Spawn(username string, s Spawn) (Unit, error) {
+	defer w.changed()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.spawn(username, s)
+}
+
+func (w *World) spawn(username string, s Spawn) (Unit, error) {
+	if w.over != nil {
+		return Unit{}, ErrGameOver
+	}
+	if w.paused {
+		return Unit{}, ErrGamePaused
+	}
+	if !w.m.Has(s.Location) {
+		return Unit{}, fmt.Errorf("%w: %s", ErrInvalidLocation, s.Location)
+	}
+	if _, ok := getAllRanks()[s.Rank]; !ok {
+		return Unit{}, fmt.Errorf("%w: %s", ErrInvalidRank, s.Rank)
+	}
+
+	if home := w.home(username); home != "" && s.Location != home {
+		return Unit{}, fmt.Errorf("%w: %s's home is %s", ErrNotHome, username, home)
+	}
+	err := w.afford(username, s.Rank)
+	if err != nil {
+		return Unit{}, err
+	}
+
+	p := w.player(username)
+	w.treasury[username] -= UnitCost(s.Rank)
+	// Not len+1: units lost in wars leave gaps.
+	id := 1
+	for existing := range p.Units {
+		id = max(id, existing+1)
+	}
+	u := Unit{
+		ID:       id,
+		Rank:     s.Rank,
+		Location: s.Location,
+	}
+	p.Units[id] = u
+	w.record(Event{Kind: EventSpawn, Player: username, Units: []Unit{u}, Location: u.Location})
+	w.updateOwners()
+	return u, nil
+}
+
+// Move carries out username's move and fights any wars it starts. Only the
+// IDs of move's units are trusted; their ranks and locations come from the
+// World. It returns the move as carried out, before the wars.
+func (w *World) Move(username string, move ArmyMove) (ArmyMove, []WarResult, error) {
+	defer w.changed()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	done, err := w.move(username, move, nil)
+	if err != nil {
+		return ArmyMove{}, nil, err
+	}
+	wars := w.fight(username, move.ToLocation)
+	w.updateOwners()
+	return done, wars, nil
+}
+
+// move moves username's units without fighting. Units in movedBefore have
+// already moved this round and cannot move again.
+func (w *World) move(username string, move ArmyMove, movedBefore map[int]bool) (ArmyMove, error) {
+	if w.over != nil {
+		return ArmyMove{}, ErrGameOver
+	}
+	if w.paused {
+		return ArmyMove{}, ErrGamePaused
+	}
+	if !w.m.Has(move.ToLocation) {
+		return ArmyMove{}, fmt.Errorf("%w: %s", ErrInvalidLocation, move.ToLocation)
+	}
+	if len(move.Units) == 0 {
+		return ArmyMove{}, ErrNoUnits
+	}
+
+	// Not w.player: a failed order must not add anyone to the world.
+	p := w.players[username]
+	moved := make([]Unit, 0, len(move.Units))
+	for _, unit := range move.Units {
+		u, ok := p.Units[unit.ID]
+		if !ok {
+			return ArmyMove{}, fmt.Errorf("%w: %s has no unit %d", ErrUnitNotFound, username, unit.ID)
+		}
+		if slices.ContainsFunc(moved, func(m Unit) bool { return m.ID == u.ID }) {
+			continue
+		}
+		if movedBefore[u.ID] {
+			return ArmyMove{}, fmt.Errorf("%w: unit %d", ErrAlreadyMoved, u.ID)
+		}
+		if u.Location != move.ToLocation && !w.m.Adjacent(u.Location, move.ToLocation) {
+			return ArmyMove{}, fmt.Errorf("%w: unit %d cannot reach %s from %s in one move", ErrNotAdjacent, u.ID, move.ToLocation, u.Location)
+		}
+		u.Location = move.ToLocation
+		moved = append(moved, u)
+	}
+	for _, u := range moved {
+		p.Units[u.ID] = u
+	}
+	w.record(Event{Kind: EventMove, Player: username, Units: moved, Location: move.ToLocation})
+	return ArmyMove{
+		Player:     copyPlayer(p),
+		Units:      moved,
+		ToLocation: move.ToLocation,
+	}, nil
+}
+
+// fight resolves a war between attacker and each other player with units at
+// loc, in username order, until the attacker has no units left there.
+func (w *World) fight(attacker string, loc Location) []WarResult {
+	defenders := []string{}
+	for name := range w.players {
+		if name != attacker {
+			defenders = append(defenders, name)
+		}
+	}
+	slices.Sort(defenders)
+
+	results := []WarResult{}
+	for _, defender := range defenders {
+		attackerUnits := unitsAt(w.players[attacker], loc)
+		defenderUnits := unitsAt(w.players[defender], loc)
+		if len(attackerUnits) == 0 {
+			break
+		}
+		if len(defenderUnits) == 0 {
+			continue
+		}
+
+		result := WarResult{
+			Attacker:      attacker,
+			Defender:      defender,
+			Location:      loc,
+			AttackerUnits: attackerUnits,
+			DefenderUnits: defenderUnits,
+		}
+		w.record(Event{Kind: EventWarDeclared, War: &result})
+		attackerPower := unitsToPowerLevel(attackerUnits)
+		defenderPower := unitsToPowerLevel(defenderUnits)
+		if attackerPower > defenderPower {
+			result.Winner = attacker
+		} else if defenderPower > attackerPower {
+			result.Winner = defender
+		}
+		w.removeCasualties(result)
+		w.record(Event{Kind: EventWarResolved, War: &result})
+		results = append(results, result)
+	}
+	return results
+}
+
+// removeCasualties removes the loser's units in the war's location, or both
+// sides' on a draw.
+func (w *World) removeCasualties(wr WarResult) {
+	if wr.Winner != wr.Attacker {
+		w.removeUnitsAt(wr.Attacker, wr.Location)
+	}
+	if wr.Winner != wr.Defender {
+		w.removeUnitsAt(wr.Defender, wr.Location)
+	}
+}
+
+func (w *World) removeUnitsAt(username string, loc Location) {
+	for id, u := range w.players[username].Units {
+		if u.Location == loc {
+			delete(w.players[username].Units, id)
+		}
+	}
+}
+
+func unitsAt(p Player, loc Location) []Unit {
+	units := []Unit{}
+	for _, u := range p.Units {
+		if u.Location == loc {
+			units = append(units, u)
+		}
+	}
+	slices.SortFunc(units, func(a, b Unit) int { return a.ID - b.ID })
+	return units
+}
+
+func copyPlayer(p Player) Player {
+	units := make(map[int]Unit, len(p.Units))
+	for id, u := range p.Units {
+		units[id] = u
+	}
+	return Player{
+		Username: p.Username,
+		Units:    units,
+	}
+}
@@ -0,0 +1,151 @@
+package gamelogic
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestWorldSpawn(t *testing.T) {
+	islands, err := LoadMap("maps/islands.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		setup func(w *World)
+		user  string
+		spawn Spawn
+		want  error
+	}{
+		{"ok", nil, "alice", Spawn{Rank: RankInfantry, Location: "europe"}, nil},
+		{"unknown location", nil, "alice", Spawn{Rank: RankInfantry, Location: "atlantis"}, ErrInvalidLocation},
+		{"unknown rank", nil, "alice", Spawn{Rank: "dragon", Location: "europe"}, ErrInvalidRank},
+		{"paused", func(w *World) { w.SetPaused(true) }, "alice", Spawn{Rank: RankInfantry, Location: "europe"}, ErrGamePaused},
+		{"game over", func(w *World) { w.over = &GameOver{} }, "alice", Spawn{Rank: RankInfantry, Location: "europe"}, ErrGameOver},
+		{"too dear", func(w *World) {
+			if _, err := w.Spawn("alice", Spawn{Rank: RankArtillery, Location: "europe"}); err != nil {
+				t.Fatal(err)
+			}
+		}, "alice", Spawn{Rank: RankArtillery, Location: "europe"}, ErrInsufficientFunds},
+		{"first home", func(w *World) { w.SetMap(islands) }, "alice", Spawn{Rank: RankInfantry, Location: "north_bay"}, nil},
+		{"away from home", func(w *World) { w.SetMap(islands) }, "alice", Spawn{Rank: RankInfantry, Location: "north_cape"}, ErrNotHome},
+		{"second home", func(w *World) {
+			w.SetMap(islands)
+			if _, err := w.Spawn("alice", Spawn{Rank: RankInfantry, Location: "north_bay"}); err != nil {
+				t.Fatal(err)
+			}
+		}, "bob", Spawn{Rank: RankInfantry, Location: "north_bay"}, ErrNotHome},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWorld()
+			if tt.setup != nil {
+				tt.setup(w)
+			}
+			before := w.Player(tt.user)
+			gold := w.Treasury(tt.user)
+
+			u, err := w.Spawn(tt.user, tt.spawn)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+			if err != nil {
+				if after := w.Player(tt.user); !reflect.DeepEqual(after, before) {
+					t.Errorf("a failed spawn changed the army from %v to %v", before, after)
+				}
+				if w.Treasury(tt.user) != gold {
+					t.Errorf("a failed spawn cost %d gold", gold-w.Treasury(tt.user))
+				}
+				return
+			}
+			if got := w.Player(tt.user).Units[u.ID]; got != u || u.Rank != tt.spawn.Rank || u.Location != tt.spawn.Location {
+				t.Errorf("spawned %v, army has %v, want a(n) %s in %s", u, got, tt.spawn.Rank, tt.spawn.Location)
+			}
+			if paid := gold - w.Treasury(tt.user); paid != UnitCost(tt.spawn.Rank) {
+				t.Errorf("paid %d gold, want %d", paid, UnitCost(tt.spawn.Rank))
+			}
+		})
+	}
+}
+
+func TestWorldMove(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(w *World)
+		user  string
+		move  ArmyMove
+		want  error
+	}{
+		{"ok", nil, "alice", ArmyMove{Units: []Unit{{ID: 1}}, ToLocation: "africa"}, nil},
+		{"stay put", nil, "alice", ArmyMove{Units: []Unit{{ID: 1}}, ToLocation: "europe"}, nil},
+		{"unknown location", nil, "alice", ArmyMove{Units: []Unit{{ID: 1}}, ToLocation: "atlantis"}, ErrInvalidLocation},
+		{"no units", nil, "alice", ArmyMove{ToLocation: "africa"}, ErrNoUnits},
+		{"unknown unit", nil, "alice", ArmyMove{Units: []Unit{{ID: 7}}, ToLocation: "africa"}, ErrUnitNotFound},
+		{"someone else's unit", func(w *World) {
+			if _, err := w.Spawn("bob", Spawn{Rank: RankInfantry, Location: "asia"}); err != nil {
+				t.Fatal(err)
+			}
+		}, "alice", ArmyMove{Units: []Unit{{ID: 1}, {ID: 2}}, ToLocation: "asia"}, ErrUnitNotFound},
+		{"unknown player", nil, "carol", ArmyMove{Units: []Unit{{ID: 1}}, ToLocation: "africa"}, ErrUnitNotFound},
+		{"not adjacent", nil, "alice", ArmyMove{Units: []Unit{{ID: 1}}, ToLocation: "australia"}, ErrNotAdjacent},
+		{"paused", func(w *World) { w.SetPaused(true) }, "alice", ArmyMove{Units: []Unit{{ID: 1}}, ToLocation: "africa"}, ErrGamePaused},
+		{"game over", func(w *World) { w.over = &GameOver{} }, "alice", ArmyMove{Units: []Unit{{ID: 1}}, ToLocation: "africa"}, ErrGameOver},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWorld()
+			if _, err := w.Spawn("alice", Spawn{Rank: RankInfantry, Location: "europe"}); err != nil {
+				t.Fatal(err)
+			}
+			if tt.setup != nil {
+				tt.setup(w)
+			}
+			before := w.Players()
+			army := w.Player(tt.user)
+
+			done, wars, err := w.Move(tt.user, tt.move)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+			if err != nil {
+				if after := w.Player(tt.user); !reflect.DeepEqual(after, army) {
+					t.Errorf("a failed move changed the army from %v to %v", army, after)
+				}
+				if after := w.Players(); !reflect.DeepEqual(after, before) {
+					t.Errorf("a failed move changed the players from %v to %v", before, after)
+				}
+				return
+			}
+			if len(wars) != 0 {
+				t.Errorf("moving into an empty territory started wars: %v", wars)
+			}
+			for _, u := range done.Units {
+				if got := w.Player(tt.user).Units[u.ID]; got.Location != tt.move.ToLocation || got.Rank != RankInfantry {
+					t.Errorf("unit %d is a(n) %s in %s, want infantry in %s", u.ID, got.Rank, got.Location, tt.move.ToLocation)
+				}
+			}
+			if owner := w.Owner(tt.move.ToLocation); owner != tt.user {
+				t.Errorf("%s is held by %q, want %s", tt.move.ToLocation, owner, tt.user)
+			}
+		})
+	}
+}
+
+func TestWorldMoveTrustsOnlyUnitIDs(t *testing.T) {
+	w := NewWorld()
+	if _, err := w.Spawn("alice", Spawn{Rank: RankInfantry, Location: "europe"}); err != nil {
+		t.Fatal(err)
+	}
+	done, _, err := w.Move("alice", ArmyMove{
+		Units:      []Unit{{ID: 1, Rank: RankArtillery, Location: "africa"}, {ID: 1}},
+		ToLocation: "asia",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Unit{{ID: 1, Rank: RankInfantry, Location: "asia"}}
+	if !reflect.DeepEqual(done.Units, want) {
+		t.Errorf("moved %v, want %v", done.Units, want)
+	}
+}
@@ -0,0 +1,76 @@
+package pubsub
+
+import (
+	"context"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Publisher is the part of a channel used to send messages.
+type Publisher interface {
+	PublishWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+}
+
+// Subscriber is the part of a channel used to declare queues and consume from them.
+type Subscriber interface {
+	QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error)
+	QueueDeclarePassive(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error)
+	QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error
+	Qos(prefetchCount, prefetchSize int, global bool) error
+	Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error)
+	Cancel(consumer string, noWait bool) error
+	Get(queue string, autoAck bool) (amqp.Delivery, bool, error)
+	QueuePurge(name string, noWait bool) (int, error)
+}
+
+// Channel is satisfied by *amqp.Channel and by the channels of a MemoryBroker.
+type Channel interface {
+	Publisher
+	Subscriber
+	ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error
+	Confirm(noWait bool) error
+	GetNextPublishSeqNo() uint64
+	NotifyPublish(receiver chan amqp.Confirmation) chan amqp.Confirmation
+	NotifyReturn(receiver chan amqp.Return) chan amqp.Return
+	NotifyClose(receiver chan *amqp.Error) chan *amqp.Error
+	Close() error
+}
+
+// Broker is a connection that hands out channels.
+type Broker interface {
+	Channel() (Channel, error)
+	NotifyClose(receiver chan *amqp.Error) chan *amqp.Error
+	Close() error
+}
+
+type amqpBroker struct {
+	conn *amqp.Connection
+}
+
+func NewAMQPBroker(conn *amqp.Connection) Broker {
+	return amqpBroker{conn: conn}
+}
+
+func Dial(url string) (Broker, error) {
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return nil, err
+	}
+	return NewAMQPBroker(conn), nil
+}
+
+func (b amqpBroker) Channel() (Channel, error) {
+	ch, err := b.conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
+
+func (b amqpBroker) NotifyClose(receiver chan *amqp.Error) chan *amqp.Error {
+	return b.conn.NotifyClose(receiver)
+}
+
+func (b amqpBroker) Close() error {
+	return b.conn.Close()
+}
@@ -0,0 +1,139 @@
+package pubsub
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+	"sync"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec turns messages into bodies and back for one content type.
+type Codec interface {
+	ContentType() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+var ErrUnknownContentType = errors.New("no codec registered for content type")
+
+var (
+	JSON    Codec = jsonCodec{}
+	GOB     Codec = gobCodec{}
+	MsgPack Codec = msgpackCodec{}
+	CBOR    Codec = cborCodec{}
+)
+
+var codecs = struct {
+	sync.RWMutex
+	byType map[string]Codec
+}{
+	byType: map[string]Codec{},
+}
+
+func init() {
+	RegisterCodec(JSON)
+	RegisterCodec(GOB)
+	RegisterCodec(MsgPack)
+	RegisterCodec(CBOR)
+	RegisterCodec(Protobuf)
+	RegisterCodecAs("application/x-msgpack", MsgPack)
+	RegisterCodecAs("application/protobuf", Protobuf)
+}
+
+// RegisterCodec makes c available to subscribers for its content type,
+// replacing any codec registered for it before.
+func RegisterCodec(c Codec) {
+	RegisterCodecAs(c.ContentType(), c)
+}
+
+// RegisterCodecAs registers c under an additional content type, for aliases
+// such as application/x-msgpack.
+func RegisterCodecAs(contentType string, c Codec) {
+	codecs.Lock()
+	defer codecs.Unlock()
+	codecs.byType[normalizeContentType(contentType)] = c
+}
+
+// CodecFor looks up the codec for a content type. Parameters such as
+// charset are ignored.
+func CodecFor(contentType string) (Codec, error) {
+	codecs.RLock()
+	defer codecs.RUnlock()
+	c, ok := codecs.byType[normalizeContentType(contentType)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownContentType, contentType)
+	}
+	return c, nil
+}
+
+func normalizeContentType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return mediaType
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string { return "application/json" }
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+type gobCodec struct{}
+
+func (gobCodec) ContentType() string { return "application/gob" }
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) ContentType() string { return "application/msgpack" }
+
+func (msgpackCodec) Marshal(v any) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v any) error {
+	return msgpack.Unmarshal(data, v)
+}
+
+type cborCodec struct{}
+
+// cborEncoding keeps sub-second precision; CBOR's default time encoding is
+// whole seconds, which would lose ordering between game logs.
+var cborEncoding, _ = cbor.EncOptions{Time: cbor.TimeRFC3339Nano}.EncMode()
+
+func (cborCodec) ContentType() string { return "application/cbor" }
+
+func (cborCodec) Marshal(v any) ([]byte, error) {
+	return cborEncoding.Marshal(v)
+}
+
+func (cborCodec) Unmarshal(data []byte, v any) error {
+	return cbor.Unmarshal(data, v)
+}
@@ -0,0 +1,119 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestCodecFor(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        Codec
+	}{
+		{"application/json", JSON},
+		{"application/json; charset=utf-8", JSON},
+		{"Application/JSON", JSON},
+		{"application/gob", GOB},
+		{"application/msgpack", MsgPack},
+		{"application/x-msgpack", MsgPack},
+		{"application/cbor", CBOR},
+		{"application/x-protobuf", Protobuf},
+		{"application/protobuf", Protobuf},
+	}
+	for _, tt := range tests {
+		got, err := CodecFor(tt.contentType)
+		if err != nil || got != tt.want {
+			t.Errorf("CodecFor(%q) = %v, %v, want %v", tt.contentType, got, err, tt.want)
+		}
+	}
+	for _, contentType := range []string{"text/plain", ""} {
+		if _, err := CodecFor(contentType); !errors.Is(err, ErrUnknownContentType) {
+			t.Errorf("CodecFor(%q): got %v, want ErrUnknownContentType", contentType, err)
+		}
+	}
+}
+
+func TestSubscribeDecodesByContentType(t *testing.T) {
+	s := NewMemoryServer()
+	ch := newMemoryChannel(t, s)
+	dead := newWorkExchange(t, ch)
+	logs := make(chan routing.GameLog, 1)
+	sub, err := SubscribeJSON(context.Background(), s.Connect(), "direct", "work", "work", Durable,
+		func(_ context.Context, gl routing.GameLog) Acktype {
+			logs <- gl
+			return Ack
+		}, WithDeadLetter("dlx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	want := routing.GameLog{
+		CurrentTime: time.Date(2024, 5, 1, 12, 30, 0, 123456789, time.UTC),
+		Message:     "alice won a war",
+		Username:    "alice",
+	}
+	tests := []struct {
+		contentType string
+		codec       Codec
+	}{
+		{"application/json", JSON},
+		// SubscribeJSON decodes messages without a content type as JSON.
+		{"", JSON},
+		{"application/gob", GOB},
+		{"application/msgpack", MsgPack},
+		{"application/x-msgpack", MsgPack},
+		{"application/cbor", CBOR},
+		{"application/x-protobuf", Protobuf},
+	}
+	for _, tt := range tests {
+		body, err := tt.codec.Marshal(want)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.contentType, err)
+		}
+		err = ch.PublishWithContext(context.Background(), "direct", "work", false, false, amqp.Publishing{
+			ContentType: tt.contentType,
+			Body:        body,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := receiveValue(t, logs)
+		if !got.CurrentTime.Equal(want.CurrentTime) || got.Message != want.Message || got.Username != want.Username {
+			t.Errorf("%q: got %+v, want %+v", tt.contentType, got, want)
+		}
+	}
+
+	publishRaw(t, ch, "text/plain", "alice won a war")
+	d := receive(t, dead)
+	if d.ContentType != "text/plain" {
+		t.Errorf("dead-lettered a %q message, want text/plain", d.ContentType)
+	}
+	if reason, _ := d.Headers[HeaderDecodeError].(string); reason == "" {
+		t.Error("the message with an unknown content type was not dead-lettered as undecodable")
+	}
+	select {
+	case gl := <-logs:
+		t.Errorf("handled %+v, which has an unknown content type", gl)
+	default:
+	}
+}
+
+func TestSubscribeUnknownContentTypeError(t *testing.T) {
+	s := NewMemoryServer()
+	ch := newMemoryChannel(t, s)
+	newWorkExchange(t, ch)
+	errs := make(chan error, 1)
+	subscribeWork(t, s, make(chan testMessage), OnError(func(err error) { errs <- err }))
+
+	publishRaw(t, ch, "text/plain", "7")
+	err := receiveValue(t, errs)
+	if !errors.Is(err, ErrUnknownContentType) {
+		t.Errorf("got %v, want ErrUnknownContentType", err)
+	}
+}
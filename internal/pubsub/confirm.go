@@ -0,0 +1,182 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+var (
+	ErrUnroutable = errors.New("message could not be routed to any queue")
+	ErrNacked     = errors.New("broker refused the message")
+)
+
+// ReturnError describes a mandatory message the broker handed back with
+// basic.return. It matches ErrUnroutable with errors.Is.
+type ReturnError struct {
+	Exchange   string
+	RoutingKey string
+	ReplyCode  uint16
+	ReplyText  string
+}
+
+func (e *ReturnError) Error() string {
+	return fmt.Sprintf("message to %s with routing key %q was returned: %d %s",
+		e.Exchange, e.RoutingKey, e.ReplyCode, e.ReplyText)
+}
+
+func (e *ReturnError) Is(target error) bool {
+	return target == ErrUnroutable
+}
+
+// ConfirmPublisher publishes on a channel in confirm mode. Every message is
+// sent as mandatory and PublishWithContext only returns once the broker has
+// acked it, nacked it, returned it as unroutable, or ctx is done.
+type ConfirmPublisher struct {
+	timeout time.Duration
+	ch      Channel
+
+	// mu makes publishes take turns, so each one knows its sequence number.
+	mu sync.Mutex
+
+	// pending holds the publishes waiting for their confirmation, by
+	// sequence number. It is guarded by its own mutex because settle
+	// needs it while a publish holds mu.
+	pendingMu sync.Mutex
+	pending   map[uint64]chan error
+	closed    bool
+}
+
+// NewConfirmPublisher opens a dedicated channel on conn. timeout bounds the
+// wait for a confirmation when the caller's context has no deadline; zero
+// means wait for as long as the context allows.
+func NewConfirmPublisher(conn Broker, timeout time.Duration) (*ConfirmPublisher, error) {
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+	err = ch.Confirm(false)
+	if err != nil {
+		ch.Close()
+		return nil, err
+	}
+	p := &ConfirmPublisher{
+		timeout: timeout,
+		ch:      ch,
+		pending: map[uint64]chan error{},
+	}
+	go p.settle(ch.NotifyPublish(make(chan amqp.Confirmation, 1)), ch.NotifyReturn(make(chan amqp.Return, 1)))
+	return p, nil
+}
+
+func (p *ConfirmPublisher) PublishWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	if _, ok := ctx.Deadline(); !ok && p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	seq := p.ch.GetNextPublishSeqNo()
+	result := make(chan error, 1)
+	p.pendingMu.Lock()
+	if p.closed {
+		p.pendingMu.Unlock()
+		return amqp.ErrClosed
+	}
+	p.pending[seq] = result
+	p.pendingMu.Unlock()
+	// A confirmation that comes after we give up is dropped by settle.
+	defer func() {
+		p.pendingMu.Lock()
+		delete(p.pending, seq)
+		p.pendingMu.Unlock()
+	}()
+
+	err := p.ch.PublishWithContext(ctx, exchange, key, true, immediate, msg)
+	if err != nil {
+		return err
+	}
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for publish confirmation: %w", ctx.Err())
+	}
+}
+
+// settle reads every confirmation and return for as long as the channel is
+// open, so late ones from publishes that timed out never hold up the
+// connection, and hands each confirmation to the publish waiting for it.
+// RabbitMQ sends basic.return before the basic.ack of the same message, so a
+// return belongs to the next confirmation.
+func (p *ConfirmPublisher) settle(confirms <-chan amqp.Confirmation, returns <-chan amqp.Return) {
+	var returned *amqp.Return
+	for confirms != nil || returns != nil {
+		select {
+		case r, ok := <-returns:
+			if !ok {
+				returns = nil
+				continue
+			}
+			returned = &r
+		case c, ok := <-confirms:
+			if !ok {
+				confirms = nil
+				continue
+			}
+			select {
+			case r, ok := <-returns:
+				if ok {
+					returned = &r
+				} else {
+					returns = nil
+				}
+			default:
+			}
+			var err error
+			switch {
+			case !c.Ack:
+				err = ErrNacked
+			case returned != nil:
+				err = &ReturnError{
+					Exchange:   returned.Exchange,
+					RoutingKey: returned.RoutingKey,
+					ReplyCode:  returned.ReplyCode,
+					ReplyText:  returned.ReplyText,
+				}
+			}
+			returned = nil
+			p.resolve(c.DeliveryTag, err)
+		}
+	}
+
+	p.pendingMu.Lock()
+	defer p.pendingMu.Unlock()
+	p.closed = true
+	for seq, result := range p.pending {
+		result <- amqp.ErrClosed
+		delete(p.pending, seq)
+	}
+}
+
+func (p *ConfirmPublisher) resolve(seq uint64, err error) {
+	p.pendingMu.Lock()
+	defer p.pendingMu.Unlock()
+	result, ok := p.pending[seq]
+	if !ok {
+		return
+	}
+	delete(p.pending, seq)
+	result <- err
+}
+
+func (p *ConfirmPublisher) Close() error {
+	return p.ch.Close()
+}
@@ -0,0 +1,140 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// heldConfirmsBroker hands out channels whose confirmations are sent by the
+// test instead of the broker, like a broker that confirms late.
+type heldConfirmsBroker struct {
+	Broker
+	confirms  chan chan amqp.Confirmation
+	published chan struct{}
+}
+
+type heldConfirmsChannel struct {
+	Channel
+	confirms  chan chan amqp.Confirmation
+	published chan struct{}
+}
+
+func (b heldConfirmsBroker) Channel() (Channel, error) {
+	ch, err := b.Broker.Channel()
+	if err != nil {
+		return nil, err
+	}
+	return heldConfirmsChannel{Channel: ch, confirms: b.confirms, published: b.published}, nil
+}
+
+func (ch heldConfirmsChannel) PublishWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	err := ch.Channel.PublishWithContext(ctx, exchange, key, mandatory, immediate, msg)
+	ch.published <- struct{}{}
+	return err
+}
+
+func (ch heldConfirmsChannel) NotifyPublish(receiver chan amqp.Confirmation) chan amqp.Confirmation {
+	ch.confirms <- receiver
+	return receiver
+}
+
+func newTestConfirmPublisher(t *testing.T, conn Broker, timeout time.Duration) *ConfirmPublisher {
+	t.Helper()
+	p, err := NewConfirmPublisher(conn, timeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+func TestConfirmPublisher(t *testing.T) {
+	s := NewMemoryServer()
+	ch := newMemoryChannel(t, s)
+	if err := ch.ExchangeDeclare("direct", amqp.ExchangeDirect, true, false, false, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ch.QueueDeclare("q", true, false, false, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := ch.QueueBind("q", "q", "direct", false, nil); err != nil {
+		t.Fatal(err)
+	}
+	p := newTestConfirmPublisher(t, s.Connect(), receiveTimeout)
+
+	for range 3 {
+		err := p.PublishWithContext(context.Background(), "direct", "q", false, false, amqp.Publishing{Body: []byte("msg")})
+		if err != nil {
+			t.Errorf("routed publish: %v", err)
+		}
+		err = p.PublishWithContext(context.Background(), "direct", "nowhere", false, false, amqp.Publishing{Body: []byte("msg")})
+		if !errors.Is(err, ErrUnroutable) {
+			t.Errorf("unroutable publish: got %v, want ErrUnroutable", err)
+		}
+	}
+	if n, err := ch.QueuePurge("q", false); err != nil || n != 3 {
+		t.Errorf("queue holds %d messages (%v), want 3", n, err)
+	}
+}
+
+func TestConfirmPublisherClosed(t *testing.T) {
+	s := NewMemoryServer()
+	p := newTestConfirmPublisher(t, s.Connect(), receiveTimeout)
+	s.Restart()
+
+	err := p.PublishWithContext(context.Background(), "", "q", false, false, amqp.Publishing{})
+	if !errors.Is(err, amqp.ErrClosed) {
+		t.Errorf("publish on a closed channel: got %v, want ErrClosed", err)
+	}
+}
+
+func TestConfirmPublisherDrainsLateConfirms(t *testing.T) {
+	s := NewMemoryServer()
+	held := heldConfirmsBroker{
+		Broker:    s.Connect(),
+		confirms:  make(chan chan amqp.Confirmation, 1),
+		published: make(chan struct{}, 3),
+	}
+	p := newTestConfirmPublisher(t, held, 10*time.Millisecond)
+	confirms := <-held.confirms
+
+	// The default exchange always exists, so these only wait for their
+	// confirmations, which never come in time.
+	for range 2 {
+		err := p.PublishWithContext(context.Background(), "", "q", false, false, amqp.Publishing{})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("got %v, want DeadlineExceeded", err)
+		}
+	}
+
+	// Whoever sends confirmations, such as the connection's reader, must not
+	// be held up by the ones nobody is waiting for any more.
+	sent := make(chan struct{})
+	go func() {
+		for tag := uint64(1); tag <= 2; tag++ {
+			confirms <- amqp.Confirmation{DeliveryTag: tag, Ack: true}
+		}
+		close(sent)
+	}()
+	select {
+	case <-sent:
+	case <-time.After(receiveTimeout):
+		t.Fatal("late confirmations were not drained")
+	}
+
+	<-held.published
+	<-held.published
+	go func() {
+		<-held.published
+		confirms <- amqp.Confirmation{DeliveryTag: 3, Ack: false}
+	}()
+	p.timeout = receiveTimeout
+	err := p.PublishWithContext(context.Background(), "", "q", false, false, amqp.Publishing{})
+	if !errors.Is(err, ErrNacked) {
+		t.Errorf("got %v, want the third publish's own nack", err)
+	}
+}
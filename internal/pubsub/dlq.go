@@ -0,0 +1,191 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// DeadLetter is a message waiting in a dead-letter queue, along with why and
+// where it died.
+type DeadLetter struct {
+	Delivery amqp.Delivery
+	Envelope Envelope
+	// Reason is the x-death reason (rejected, expired, maxlen), or the decode
+	// error for messages that never reached a handler.
+	Reason string
+	// Queue is the queue the message died in.
+	Queue string
+	// Exchange and RoutingKey are where the message was originally
+	// published, and where a replay sends it.
+	Exchange   string
+	RoutingKey string
+	Deaths     int64
+}
+
+func newDeadLetter(d amqp.Delivery) DeadLetter {
+	dl := DeadLetter{
+		Delivery:   d,
+		Envelope:   envelopeFromDelivery(d),
+		Exchange:   d.Exchange,
+		RoutingKey: d.RoutingKey,
+	}
+	// x-death lists the most recent death first.
+	if deaths, ok := d.Headers["x-death"].([]interface{}); ok && len(deaths) > 0 {
+		if t, ok := deaths[0].(amqp.Table); ok {
+			dl.Reason, _ = t["reason"].(string)
+			dl.Queue, _ = t["queue"].(string)
+			dl.Exchange, _ = t["exchange"].(string)
+			if keys, ok := t["routing-keys"].([]interface{}); ok && len(keys) > 0 {
+				dl.RoutingKey, _ = keys[0].(string)
+			}
+		}
+		for _, death := range deaths {
+			if t, ok := death.(amqp.Table); ok {
+				n, _ := tableInt(t["count"])
+				dl.Deaths += n
+			}
+		}
+	}
+	if reason, ok := d.Headers[HeaderDecodeError].(string); ok {
+		dl.Reason = "undecodable: " + reason
+		dl.Deaths++
+	}
+	if q, ok := d.Headers[HeaderOriginalQueue].(string); ok {
+		dl.Queue = q
+	}
+	if ex, ok := d.Headers[HeaderOriginalExchange].(string); ok {
+		dl.Exchange = ex
+		dl.RoutingKey, _ = d.Headers[HeaderOriginalRoutingKey].(string)
+	}
+	return dl
+}
+
+// Body renders the message for people: protobuf messages of a known type
+// and JSON are shown as JSON, anything else as a size and content type.
+func (dl DeadLetter) Body() string {
+	d := dl.Delivery
+	switch normalizeContentType(d.ContentType) {
+	case Protobuf.ContentType(), "application/protobuf":
+		mt, err := protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName(d.Type))
+		if err != nil {
+			break
+		}
+		m := mt.New().Interface()
+		if proto.Unmarshal(d.Body, m) != nil {
+			break
+		}
+		return protojson.Format(m)
+	case JSON.ContentType(), "":
+		if json.Valid(d.Body) {
+			return string(d.Body)
+		}
+	case MsgPack.ContentType(), CBOR.ContentType():
+		codec, _ := CodecFor(d.ContentType)
+		var v any
+		if codec.Unmarshal(d.Body, &v) == nil {
+			return fmt.Sprintf("%v", v)
+		}
+	}
+	return fmt.Sprintf("<%d bytes of %s>", len(d.Body), d.ContentType)
+}
+
+// ListDeadLetters returns every message in queue, oldest first, and leaves
+// them all in place.
+func ListDeadLetters(ch Subscriber, queue string) ([]DeadLetter, error) {
+	deliveries, err := getAll(ch, queue)
+	defer requeueAll(deliveries)
+	if err != nil {
+		return nil, err
+	}
+	dls := make([]DeadLetter, 0, len(deliveries))
+	for _, d := range deliveries {
+		dls = append(dls, newDeadLetter(d))
+	}
+	return dls, nil
+}
+
+// ReplayDeadLetters republishes the messages at the given positions in
+// queue (as listed by ListDeadLetters) to their original exchange and
+// routing key, and removes them from queue. With no positions it replays
+// every message. It returns how many were replayed.
+func ReplayDeadLetters(ctx context.Context, ch Channel, queue string, positions ...int) (int, error) {
+	deliveries, err := getAll(ch, queue)
+	if err != nil {
+		requeueAll(deliveries)
+		return 0, err
+	}
+	replay := map[int]bool{}
+	for _, i := range positions {
+		if i < 0 || i >= len(deliveries) {
+			requeueAll(deliveries)
+			return 0, fmt.Errorf("no dead letter at position %d, the queue has %d", i, len(deliveries))
+		}
+		replay[i] = true
+	}
+
+	replayed := 0
+	var kept []amqp.Delivery
+	for i, d := range deliveries {
+		if len(positions) > 0 && !replay[i] {
+			kept = append(kept, d)
+			continue
+		}
+		dl := newDeadLetter(d)
+		err = ch.PublishWithContext(ctx, dl.Exchange, dl.RoutingKey, false, false, replayPublishing(d))
+		if err != nil {
+			requeueAll(append(kept, deliveries[i:]...))
+			return replayed, err
+		}
+		d.Ack(false)
+		replayed++
+	}
+	requeueAll(kept)
+	return replayed, nil
+}
+
+// replayPublishing strips the dead-lettering and retry headers, so the
+// replayed message starts over with a fresh attempt count.
+func replayPublishing(d amqp.Delivery) amqp.Publishing {
+	pub := deliveryToPublishing(d)
+	for k := range pub.Headers {
+		if strings.HasPrefix(k, "x-death") || strings.HasPrefix(k, "x-first-death-") || strings.HasPrefix(k, "x-last-death-") {
+			delete(pub.Headers, k)
+		}
+	}
+	delete(pub.Headers, HeaderDecodeError)
+	delete(pub.Headers, HeaderOriginalExchange)
+	delete(pub.Headers, HeaderOriginalRoutingKey)
+	delete(pub.Headers, HeaderOriginalQueue)
+	delete(pub.Headers, HeaderAttempt)
+	return pub
+}
+
+func getAll(ch Subscriber, queue string) ([]amqp.Delivery, error) {
+	var deliveries []amqp.Delivery
+	for {
+		d, ok, err := ch.Get(queue, false)
+		if err != nil {
+			return deliveries, err
+		}
+		if !ok {
+			return deliveries, nil
+		}
+		deliveries = append(deliveries, d)
+	}
+}
+
+// requeueAll puts deliveries back in their original order: requeued
+// messages go to the head of the queue, so the newest goes first.
+func requeueAll(deliveries []amqp.Delivery) {
+	for i := len(deliveries) - 1; i >= 0; i-- {
+		deliveries[i].Nack(false, true)
+	}
+}
@@ -0,0 +1,178 @@
+package pubsub
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// newDeadLetterQueue declares queue q, bound to the direct exchange with
+// key q, which dead-letters to dlq through the dlx exchange, and rejects
+// each of bodies from it.
+func newDeadLetterQueue(t *testing.T, ch Channel, bodies ...string) {
+	t.Helper()
+	if err := ch.ExchangeDeclare("direct", amqp.ExchangeDirect, true, false, false, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := ch.ExchangeDeclare("dlx", amqp.ExchangeFanout, true, false, false, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ch.QueueDeclare("dlq", true, false, false, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := ch.QueueBind("dlq", "", "dlx", false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ch.QueueDeclare("q", true, false, false, false, amqp.Table{"x-dead-letter-exchange": "dlx"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ch.QueueBind("q", "q", "direct", false, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, body := range bodies {
+		publish(t, ch, "direct", "q", body)
+		d, ok, err := ch.Get("q", false)
+		if err != nil || !ok {
+			t.Fatalf("could not get %q back: ok=%v err=%v", body, ok, err)
+		}
+		if err := d.Nack(false, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// queueBodies empties queue and returns what it held, in order.
+func queueBodies(t *testing.T, ch Channel, queue string) []string {
+	t.Helper()
+	var bodies []string
+	for {
+		body, ok := getBody(t, ch, queue)
+		if !ok {
+			return bodies
+		}
+		bodies = append(bodies, body)
+	}
+}
+
+func TestNewDeadLetter(t *testing.T) {
+	tests := []struct {
+		name     string
+		delivery amqp.Delivery
+		want     DeadLetter
+	}{
+		{
+			"no headers",
+			amqp.Delivery{Exchange: "dlx", RoutingKey: "q"},
+			DeadLetter{Exchange: "dlx", RoutingKey: "q"},
+		},
+		{
+			"x-death",
+			amqp.Delivery{Exchange: "dlx", RoutingKey: "q", Headers: amqp.Table{"x-death": []interface{}{
+				amqp.Table{"reason": "expired", "queue": "q.retry.5s", "exchange": "", "routing-keys": []interface{}{"q.retry.5s"}, "count": int64(2)},
+				amqp.Table{"reason": "rejected", "queue": "q", "exchange": "direct", "routing-keys": []interface{}{"q"}, "count": int32(3)},
+			}}},
+			DeadLetter{Reason: "expired", Queue: "q.retry.5s", Exchange: "", RoutingKey: "q.retry.5s", Deaths: 5},
+		},
+		{
+			"undecodable",
+			amqp.Delivery{Exchange: "dlx", RoutingKey: "q", Headers: amqp.Table{
+				HeaderDecodeError:        "unexpected end of JSON input",
+				HeaderOriginalExchange:   "direct",
+				HeaderOriginalRoutingKey: "q.alice",
+				HeaderOriginalQueue:      "q",
+			}},
+			DeadLetter{Reason: "undecodable: unexpected end of JSON input", Queue: "q", Exchange: "direct", RoutingKey: "q.alice", Deaths: 1},
+		},
+		{
+			"retried until dead",
+			amqp.Delivery{Exchange: "dlx", RoutingKey: "q", Headers: amqp.Table{
+				HeaderOriginalExchange: "direct",
+				HeaderOriginalQueue:    "q",
+				HeaderAttempt:          int32(3),
+			}},
+			// No original routing key means the message had none.
+			DeadLetter{Queue: "q", Exchange: "direct", RoutingKey: ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newDeadLetter(tt.delivery)
+			got.Delivery, got.Envelope = amqp.Delivery{}, Envelope{}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListDeadLetters(t *testing.T) {
+	ch := newMemoryChannel(t, NewMemoryServer())
+	newDeadLetterQueue(t, ch, "first", "second", "third")
+
+	for range 2 {
+		dls, err := ListDeadLetters(ch, "dlq")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var bodies []string
+		for _, dl := range dls {
+			bodies = append(bodies, string(dl.Delivery.Body))
+			if dl.Reason != "rejected" || dl.Queue != "q" || dl.Exchange != "direct" || dl.RoutingKey != "q" || dl.Deaths != 1 {
+				t.Errorf("dead letter %+v, want rejected once from q, published to direct with key q", dl)
+			}
+		}
+		if want := []string{"first", "second", "third"}; !slices.Equal(bodies, want) {
+			t.Errorf("listed %v, want %v", bodies, want)
+		}
+	}
+}
+
+func TestReplayDeadLetters(t *testing.T) {
+	ch := newMemoryChannel(t, NewMemoryServer())
+	newDeadLetterQueue(t, ch, "first", "second", "third")
+
+	if _, err := ReplayDeadLetters(context.Background(), ch, "dlq", 3); err == nil {
+		t.Error("replaying a position past the end did not fail")
+	}
+
+	n, err := ReplayDeadLetters(context.Background(), ch, "dlq", 1)
+	if err != nil || n != 1 {
+		t.Fatalf("replayed %d (%v), want 1", n, err)
+	}
+	d, ok, err := ch.Get("q", true)
+	if err != nil || !ok {
+		t.Fatalf("nothing replayed to q: ok=%v err=%v", ok, err)
+	}
+	if string(d.Body) != "second" {
+		t.Errorf("replayed %q, want second", d.Body)
+	}
+	if _, ok := d.Headers["x-death"]; ok {
+		t.Error("the replayed message kept its x-death header")
+	}
+
+	n, err = ReplayDeadLetters(context.Background(), ch, "dlq")
+	if err != nil || n != 2 {
+		t.Fatalf("replayed %d (%v), want 2", n, err)
+	}
+	if got, want := queueBodies(t, ch, "q"), []string{"first", "third"}; !slices.Equal(got, want) {
+		t.Errorf("q holds %v, want %v", got, want)
+	}
+	if left := queueBodies(t, ch, "dlq"); len(left) != 0 {
+		t.Errorf("dlq still holds %v", left)
+	}
+}
+
+func TestReplayDeadLettersKeepsTheRest(t *testing.T) {
+	ch := newMemoryChannel(t, NewMemoryServer())
+	newDeadLetterQueue(t, ch, "first", "second", "third")
+
+	if _, err := ReplayDeadLetters(context.Background(), ch, "dlq", 0, 2); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := queueBodies(t, ch, "dlq"), []string{"second"}; !slices.Equal(got, want) {
+		t.Errorf("dlq holds %v, want %v", got, want)
+	}
+}
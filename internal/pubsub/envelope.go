@@ -0,0 +1,219 @@
+package pubsub
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	HeaderSchemaVersion = "x-peril-schema-version"
+	HeaderSender        = "x-peril-sender"
+	HeaderCausationID   = "x-peril-causation-id"
+)
+
+var ErrUnsupportedSchema = errors.New("unsupported schema version")
+
+// Envelope is the metadata carried by every message Publish sends. The
+// message ID, type, timestamp and correlation ID use the AMQP properties of
+// the same name; the rest are x-peril-* headers.
+type Envelope struct {
+	MessageID     string
+	SchemaVersion int
+	Type          string
+	Sender        string
+	CreatedAt     time.Time
+	CorrelationID string
+	CausationID   string
+	// RoutingKey is the key the message was delivered with. For per-player
+	// keys, routing.ParseKey tells whose message it is.
+	RoutingKey  string
+	ContentType string
+	// ReplyTo is set on RPC requests; see Serve.
+	ReplyTo string
+	// Attempt counts deliveries to a handler, starting at 1; it goes up each
+	// time the message comes back through NackRetryLater.
+	Attempt int
+}
+
+// SchemaVersioner is implemented by message types whose wire layout has
+// changed. Types without it are version 1.
+type SchemaVersioner interface {
+	SchemaVersion() int
+}
+
+// Upcaster rewrites a delivery of one schema version into the next one, so
+// a subscriber can keep accepting messages from older publishers.
+type Upcaster func(d amqp.Delivery) (amqp.Delivery, error)
+
+type (
+	envelopeKey    struct{}
+	senderKey      struct{}
+	correlationKey struct{}
+)
+
+// WithSender returns a context whose publishes are stamped with username
+// as their sender.
+func WithSender(ctx context.Context, username string) context.Context {
+	return context.WithValue(ctx, senderKey{}, username)
+}
+
+// WithCorrelationID returns a context whose publishes carry id as their
+// correlation ID instead of the one inherited from the message being handled.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationKey{}, id)
+}
+
+// EnvelopeFromContext returns the envelope of the message a handler was
+// called with.
+func EnvelopeFromContext(ctx context.Context) (Envelope, bool) {
+	env, ok := ctx.Value(envelopeKey{}).(Envelope)
+	return env, ok
+}
+
+func contextWithEnvelope(ctx context.Context, env Envelope) context.Context {
+	return context.WithValue(ctx, envelopeKey{}, env)
+}
+
+// newEnvelope builds the envelope for publishing val. A message published
+// while handling another one is caused by it and shares its correlation ID.
+func newEnvelope(ctx context.Context, val any) Envelope {
+	env := Envelope{
+		MessageID:     newMessageID(),
+		SchemaVersion: schemaVersionOf(val),
+		Type:          typeName(val),
+		CreatedAt:     time.Now(),
+	}
+	env.Sender, _ = ctx.Value(senderKey{}).(string)
+	if parent, ok := EnvelopeFromContext(ctx); ok {
+		env.CausationID = parent.MessageID
+		env.CorrelationID = parent.CorrelationID
+		if env.CorrelationID == "" {
+			env.CorrelationID = parent.MessageID
+		}
+	}
+	if id, ok := ctx.Value(correlationKey{}).(string); ok {
+		env.CorrelationID = id
+	}
+	if env.CorrelationID == "" {
+		env.CorrelationID = env.MessageID
+	}
+	return env
+}
+
+func (env Envelope) apply(pub *amqp.Publishing) {
+	if pub.Headers == nil {
+		pub.Headers = amqp.Table{}
+	}
+	pub.MessageId = env.MessageID
+	pub.Type = env.Type
+	pub.Timestamp = env.CreatedAt
+	pub.CorrelationId = env.CorrelationID
+	pub.Headers[HeaderSchemaVersion] = int32(env.SchemaVersion)
+	if env.Sender != "" {
+		pub.Headers[HeaderSender] = env.Sender
+	}
+	if env.CausationID != "" {
+		pub.Headers[HeaderCausationID] = env.CausationID
+	}
+}
+
+// envelopeFromDelivery reads the envelope back. Messages from publishers
+// that predate the envelope are treated as schema version 1.
+func envelopeFromDelivery(d amqp.Delivery) Envelope {
+	env := Envelope{
+		MessageID:     d.MessageId,
+		SchemaVersion: 1,
+		Type:          d.Type,
+		CreatedAt:     d.Timestamp,
+		CorrelationID: d.CorrelationId,
+		RoutingKey:    d.RoutingKey,
+		ReplyTo:       d.ReplyTo,
+		ContentType:   d.ContentType,
+		Attempt:       attemptOf(d),
+	}
+	if v, ok := tableInt(d.Headers[HeaderSchemaVersion]); ok {
+		env.SchemaVersion = int(v)
+	}
+	env.Sender, _ = d.Headers[HeaderSender].(string)
+	env.CausationID, _ = d.Headers[HeaderCausationID].(string)
+	// Retried messages come back keyed by queue name.
+	if key, ok := d.Headers[HeaderOriginalRoutingKey].(string); ok {
+		env.RoutingKey = key
+	}
+	return env
+}
+
+// KeyUser is the player whose per-player routing key the message was
+// published with, such as alice for army_moves.alice.
+func (env Envelope) KeyUser() (string, error) {
+	_, user, err := routing.ParseKey(env.RoutingKey)
+	return user, err
+}
+
+// upcast brings d up to the subscription's schema version, or fails with
+// ErrUnsupportedSchema if it is newer or no upcaster covers the gap.
+func (cfg subscribeConfig) upcast(d amqp.Delivery) (amqp.Delivery, error) {
+	version := envelopeFromDelivery(d).SchemaVersion
+	if version > cfg.schemaVersion {
+		return d, fmt.Errorf("%w: %d is newer than %d", ErrUnsupportedSchema, version, cfg.schemaVersion)
+	}
+	for ; version < cfg.schemaVersion; version++ {
+		up, ok := cfg.upcasters[version]
+		if !ok {
+			return d, fmt.Errorf("%w: no upcaster from version %d", ErrUnsupportedSchema, version)
+		}
+		var err error
+		d, err = up(d)
+		if err != nil {
+			return d, fmt.Errorf("upcasting from version %d: %w", version, err)
+		}
+		if d.Headers == nil {
+			d.Headers = amqp.Table{}
+		}
+		d.Headers[HeaderSchemaVersion] = int32(version + 1)
+	}
+	return d, nil
+}
+
+func schemaVersionOf(v any) int {
+	if sv, ok := v.(SchemaVersioner); ok {
+		return sv.SchemaVersion()
+	}
+	return 1
+}
+
+// typeName is the protobuf full name for types with a protobuf form, so
+// non-Go consumers can recognize them, and the Go type name otherwise.
+func typeName(v any) string {
+	switch m := v.(type) {
+	case proto.Message:
+		return string(m.ProtoReflect().Descriptor().FullName())
+	case ProtoMarshaler:
+		return string(m.ToProto().ProtoReflect().Descriptor().FullName())
+	}
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil {
+		return ""
+	}
+	return t.String()
+}
+
+// newMessageID returns a random (version 4) UUID.
+func newMessageID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,236 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// testMessageV2 is testMessage after N was renamed to Count.
+type testMessageV2 struct {
+	Count int
+}
+
+func (testMessageV2) SchemaVersion() int { return 2 }
+
+// upcastTestMessage turns a testMessage body into a testMessageV2 one.
+func upcastTestMessage(d amqp.Delivery) (amqp.Delivery, error) {
+	var v1 testMessage
+	if err := json.Unmarshal(d.Body, &v1); err != nil {
+		return d, err
+	}
+	body, err := json.Marshal(testMessageV2{Count: v1.N})
+	if err != nil {
+		return d, err
+	}
+	d.Body = body
+	return d, nil
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestEnvelopeMetadata(t *testing.T) {
+	s := NewMemoryServer()
+	ch := newMemoryChannel(t, s)
+	newWorkExchange(t, ch)
+	envs := make(chan Envelope, 2)
+	sub, err := SubscribeJSON(context.Background(), s.Connect(), "direct", "work", "work", Durable,
+		func(ctx context.Context, m testMessage) Acktype {
+			env, ok := EnvelopeFromContext(ctx)
+			if !ok {
+				t.Error("the handler's context has no envelope")
+			}
+			envs <- env
+			// What a handler publishes is caused by what it handles.
+			if m.N == 1 {
+				if err := PublishJSON(ctx, ch, "direct", "work", testMessage{N: 2}); err != nil {
+					t.Error(err)
+				}
+			}
+			return Ack
+		}, WithDeadLetter("dlx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	before := time.Now()
+	if err := PublishJSON(WithSender(context.Background(), "alice"), ch, "direct", "work", testMessage{N: 1}); err != nil {
+		t.Fatal(err)
+	}
+	first := receiveValue(t, envs)
+	if !uuidPattern.MatchString(first.MessageID) {
+		t.Errorf("message ID %q is not a UUID", first.MessageID)
+	}
+	want := Envelope{
+		MessageID:     first.MessageID,
+		SchemaVersion: 1,
+		Type:          "pubsub.testMessage",
+		Sender:        "alice",
+		CreatedAt:     first.CreatedAt,
+		CorrelationID: first.MessageID,
+		RoutingKey:    "work",
+		ContentType:   JSON.ContentType(),
+		Attempt:       1,
+	}
+	if first != want {
+		t.Errorf("got %+v, want %+v", first, want)
+	}
+	if first.CreatedAt.Before(before.Truncate(time.Second)) || first.CreatedAt.After(time.Now()) {
+		t.Errorf("created at %v, want around %v", first.CreatedAt, before)
+	}
+
+	second := receiveValue(t, envs)
+	if second.MessageID == first.MessageID {
+		t.Error("both messages have the same ID")
+	}
+	if second.CausationID != first.MessageID || second.CorrelationID != first.CorrelationID {
+		t.Errorf("caused by %q in conversation %q, want %q in %q", second.CausationID, second.CorrelationID, first.MessageID, first.CorrelationID)
+	}
+	if second.Sender != "" {
+		t.Errorf("the sender %q was inherited", second.Sender)
+	}
+}
+
+func TestNewEnvelopeCorrelationID(t *testing.T) {
+	parent := Envelope{MessageID: "parent", CorrelationID: "conversation"}
+	ctx := contextWithEnvelope(context.Background(), parent)
+	if env := newEnvelope(ctx, testMessage{}); env.CorrelationID != "conversation" || env.CausationID != "parent" {
+		t.Errorf("got correlation %q and causation %q, want conversation and parent", env.CorrelationID, env.CausationID)
+	}
+	ctx = WithCorrelationID(ctx, "request")
+	if env := newEnvelope(ctx, testMessage{}); env.CorrelationID != "request" || env.CausationID != "parent" {
+		t.Errorf("got correlation %q and causation %q, want request and parent", env.CorrelationID, env.CausationID)
+	}
+}
+
+func TestEnvelopeFromOlderPublisher(t *testing.T) {
+	env := envelopeFromDelivery(amqp.Delivery{RoutingKey: "game_logs.alice"})
+	if env.SchemaVersion != 1 || env.Attempt != 1 {
+		t.Errorf("schema version %d and attempt %d, want 1 and 1", env.SchemaVersion, env.Attempt)
+	}
+	if user, err := env.KeyUser(); err != nil || user != "alice" {
+		t.Errorf("KeyUser() = %q, %v, want alice", user, err)
+	}
+}
+
+func TestUpcast(t *testing.T) {
+	addOne := func(d amqp.Delivery) (amqp.Delivery, error) {
+		d.Body = append(d.Body, '+')
+		return d, nil
+	}
+	cfg := subscribeConfig{schemaVersion: 3, upcasters: map[int]Upcaster{1: addOne, 2: addOne}}
+	tests := []struct {
+		version int32
+		body    string
+		err     error
+	}{
+		{1, "v++", nil},
+		{2, "v+", nil},
+		{3, "v", nil},
+		{4, "", ErrUnsupportedSchema},
+	}
+	for _, tt := range tests {
+		d, err := cfg.upcast(amqp.Delivery{Body: []byte("v"), Headers: amqp.Table{HeaderSchemaVersion: tt.version}})
+		if !errors.Is(err, tt.err) {
+			t.Errorf("version %d: got %v, want %v", tt.version, err, tt.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if string(d.Body) != tt.body {
+			t.Errorf("version %d: upcast to %q, want %q", tt.version, d.Body, tt.body)
+		}
+		if v := envelopeFromDelivery(d).SchemaVersion; v != 3 {
+			t.Errorf("version %d: upcast to version %d, want 3", tt.version, v)
+		}
+	}
+
+	delete(cfg.upcasters, 1)
+	if _, err := cfg.upcast(amqp.Delivery{}); !errors.Is(err, ErrUnsupportedSchema) {
+		t.Errorf("without an upcaster from version 1: got %v, want ErrUnsupportedSchema", err)
+	}
+}
+
+func TestSubscribeUpcasts(t *testing.T) {
+	s := NewMemoryServer()
+	ch := newMemoryChannel(t, s)
+	newWorkExchange(t, ch)
+	type handled struct {
+		m   testMessageV2
+		env Envelope
+	}
+	got := make(chan handled, 1)
+	sub, err := SubscribeJSON(context.Background(), s.Connect(), "direct", "work", "work", Durable,
+		func(ctx context.Context, m testMessageV2) Acktype {
+			env, _ := EnvelopeFromContext(ctx)
+			got <- handled{m, env}
+			return Ack
+		}, WithDeadLetter("dlx"), WithUpcaster(1, upcastTestMessage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	if err := PublishJSON(context.Background(), ch, "direct", "work", testMessage{N: 3}); err != nil {
+		t.Fatal(err)
+	}
+	h := receiveValue(t, got)
+	if h.m.Count != 3 || h.env.SchemaVersion != 2 {
+		t.Errorf("got %+v at version %d, want Count 3 at version 2", h.m, h.env.SchemaVersion)
+	}
+
+	if err := PublishJSON(context.Background(), ch, "direct", "work", testMessageV2{Count: 4}); err != nil {
+		t.Fatal(err)
+	}
+	if h := receiveValue(t, got); h.m.Count != 4 {
+		t.Errorf("got %+v, want Count 4", h.m)
+	}
+}
+
+func TestSubscribeUnsupportedSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		version int
+		opts    []SubscribeOption
+	}{
+		{"newer", 2, nil},
+		{"no upcaster", 1, []SubscribeOption{WithSchemaVersion(2)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMemoryServer()
+			ch := newMemoryChannel(t, s)
+			dead := newWorkExchange(t, ch)
+			errs := make(chan error, 1)
+			handled := make(chan testMessage, 1)
+			subscribeWork(t, s, handled, append(tt.opts, OnError(func(err error) { errs <- err }))...)
+
+			err := ch.PublishWithContext(context.Background(), "direct", "work", false, false, amqp.Publishing{
+				ContentType: JSON.ContentType(),
+				Headers:     amqp.Table{HeaderSchemaVersion: int32(tt.version)},
+				Body:        []byte(`{"N": 1}`),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := receiveValue(t, errs); !errors.Is(err, ErrUnsupportedSchema) {
+				t.Errorf("got %v, want ErrUnsupportedSchema", err)
+			}
+			if d := receive(t, dead); d.Headers[HeaderDecodeError] == nil {
+				t.Error("dead letter has no decode error")
+			}
+			select {
+			case m := <-handled:
+				t.Errorf("handled %+v", m)
+			default:
+			}
+		})
+	}
+}
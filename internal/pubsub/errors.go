@@ -0,0 +1,80 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	HeaderDecodeError        = "x-peril-decode-error"
+	HeaderOriginalExchange   = "x-peril-original-exchange"
+	HeaderOriginalRoutingKey = "x-peril-original-routing-key"
+	HeaderOriginalQueue      = "x-peril-original-queue"
+)
+
+// DecodeError is reported for a delivery whose body could not be decoded
+// into the subscription's message type. Such messages never reach the
+// handler.
+type DecodeError struct {
+	Queue       string
+	Exchange    string
+	RoutingKey  string
+	ContentType string
+	Err         error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("could not decode %s message from %s (routing key %q) on queue %s: %v",
+		e.ContentType, e.Exchange, e.RoutingKey, e.Queue, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// rejectPoison moves an undecodable delivery to the dead-letter exchange with
+// headers describing why. If that publish fails, or the queue has no
+// dead-letter exchange, the delivery is nacked instead.
+func rejectPoison(ch Publisher, dlx string, d amqp.Delivery, decodeErr *DecodeError) error {
+	if dlx == "" {
+		return d.Nack(false, false)
+	}
+
+	pub := deliveryToPublishing(d)
+	pub.Headers[HeaderDecodeError] = decodeErr.Err.Error()
+	pub.Headers[HeaderOriginalExchange] = d.Exchange
+	pub.Headers[HeaderOriginalRoutingKey] = d.RoutingKey
+	pub.Headers[HeaderOriginalQueue] = decodeErr.Queue
+
+	err := ch.PublishWithContext(context.Background(), dlx, d.RoutingKey, false, false, pub)
+	if err != nil {
+		d.Nack(false, false)
+		return err
+	}
+	return d.Ack(false)
+}
+
+func deliveryToPublishing(d amqp.Delivery) amqp.Publishing {
+	headers := amqp.Table{}
+	for k, v := range d.Headers {
+		headers[k] = v
+	}
+	return amqp.Publishing{
+		Headers:         headers,
+		ContentType:     d.ContentType,
+		ContentEncoding: d.ContentEncoding,
+		DeliveryMode:    d.DeliveryMode,
+		Priority:        d.Priority,
+		CorrelationId:   d.CorrelationId,
+		ReplyTo:         d.ReplyTo,
+		Expiration:      d.Expiration,
+		MessageId:       d.MessageId,
+		Timestamp:       d.Timestamp,
+		Type:            d.Type,
+		UserId:          d.UserId,
+		AppId:           d.AppId,
+		Body:            d.Body,
+	}
+}
@@ -0,0 +1,1048 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// MemoryServer is an in-process stand-in for a RabbitMQ node. Connections made
+// with Connect share its exchanges and queues, so several Peril participants
+// can run inside one binary.
+type MemoryServer struct {
+	mu        sync.Mutex
+	exchanges map[string]*memExchange
+	queues    map[string]*memQueue
+	conns     map[*MemoryBroker]struct{}
+	nextID    int
+}
+
+// MemoryBroker is a single connection to a MemoryServer.
+type MemoryBroker struct {
+	server   *MemoryServer
+	closed   bool
+	channels map[*memChannel]struct{}
+	notify   []chan *amqp.Error
+}
+
+type memExchange struct {
+	name       string
+	kind       string
+	durable    bool
+	autoDelete bool
+	bindings   []memBinding
+}
+
+type memBinding struct {
+	queue string
+	key   string
+}
+
+type memMessage struct {
+	exchange    string
+	key         string
+	pub         amqp.Publishing
+	redelivered bool
+	expires     time.Time
+}
+
+type memQueue struct {
+	name        string
+	durable     bool
+	autoDelete  bool
+	exclusive   bool
+	args        amqp.Table
+	owner       *MemoryBroker
+	ready       []memMessage
+	consumers   []*memConsumer
+	next        int
+	hadConsumer bool
+}
+
+type memConsumer struct {
+	tag       string
+	ch        *memChannel
+	queue     *memQueue
+	autoAck   bool
+	exclusive bool
+	prefetch  int
+	unacked   int
+	pending   []amqp.Delivery
+	cond      *sync.Cond
+	out       chan amqp.Delivery
+	done      chan struct{}
+	cancelled bool
+}
+
+type memChannel struct {
+	conn      *MemoryBroker
+	closed    bool
+	prefetch  int
+	nextTag   uint64
+	unacked   map[uint64]memUnacked
+	consumers map[string]*memConsumer
+	notify    []chan *amqp.Error
+
+	confirm    bool
+	publishSeq uint64
+
+	// Publisher confirms and returns are sent outside the server lock, so
+	// they are guarded by their own mutex.
+	eventsMu     sync.Mutex
+	eventsClosed bool
+	confirms     []chan amqp.Confirmation
+	returns      []chan amqp.Return
+}
+
+type memUnacked struct {
+	msg      memMessage
+	queue    *memQueue
+	consumer *memConsumer
+}
+
+func NewMemoryServer() *MemoryServer {
+	return &MemoryServer{
+		exchanges: map[string]*memExchange{},
+		queues:    map[string]*memQueue{},
+		conns:     map[*MemoryBroker]struct{}{},
+	}
+}
+
+func (s *MemoryServer) Connect() *MemoryBroker {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conn := &MemoryBroker{
+		server:   s,
+		channels: map[*memChannel]struct{}{},
+	}
+	s.conns[conn] = struct{}{}
+	return conn
+}
+
+// Restart simulates a node restart: every connection is dropped, transient
+// exchanges and queues disappear and durable queues keep only persistent
+// messages. Quorum queues, like RabbitMQ's, keep all of them.
+func (s *MemoryServer) Restart() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		s.closeConnLocked(conn, memError(amqp.ConnectionForced, "broker restarted"))
+	}
+	for name, ex := range s.exchanges {
+		if !ex.durable {
+			delete(s.exchanges, name)
+		}
+	}
+	for _, q := range s.queues {
+		if !q.durable {
+			s.deleteQueueLocked(q)
+			continue
+		}
+		kept := []memMessage{}
+		for _, m := range q.ready {
+			if m.pub.DeliveryMode == amqp.Persistent || q.args["x-queue-type"] == "quorum" {
+				kept = append(kept, m)
+			}
+		}
+		q.ready = kept
+	}
+}
+
+func (b *MemoryBroker) Channel() (Channel, error) {
+	s := b.server
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if b.closed {
+		return nil, amqp.ErrClosed
+	}
+	ch := &memChannel{
+		conn:      b,
+		unacked:   map[uint64]memUnacked{},
+		consumers: map[string]*memConsumer{},
+	}
+	b.channels[ch] = struct{}{}
+	return ch, nil
+}
+
+func (b *MemoryBroker) Close() error {
+	s := b.server
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if b.closed {
+		return amqp.ErrClosed
+	}
+	s.closeConnLocked(b, nil)
+	return nil
+}
+
+func (b *MemoryBroker) NotifyClose(receiver chan *amqp.Error) chan *amqp.Error {
+	s := b.server
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if b.closed {
+		close(receiver)
+		return receiver
+	}
+	b.notify = append(b.notify, receiver)
+	return receiver
+}
+
+func (s *MemoryServer) closeConnLocked(b *MemoryBroker, err *amqp.Error) {
+	for ch := range b.channels {
+		s.closeChannelLocked(ch, err)
+	}
+	for _, q := range s.queues {
+		if q.exclusive && q.owner == b {
+			s.deleteQueueLocked(q)
+		}
+	}
+	b.closed = true
+	delete(s.conns, b)
+	notifyClosed(b.notify, err)
+	b.notify = nil
+}
+
+func (ch *memChannel) server() *MemoryServer {
+	return ch.conn.server
+}
+
+func (ch *memChannel) ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error {
+	s := ch.server()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ch.closed {
+		return amqp.ErrClosed
+	}
+	switch kind {
+	case amqp.ExchangeDirect, amqp.ExchangeTopic, amqp.ExchangeFanout:
+	default:
+		return memError(amqp.NotImplemented, "exchange type '%s' is not supported", kind)
+	}
+	if ex, ok := s.exchanges[name]; ok {
+		if ex.kind != kind || ex.durable != durable || ex.autoDelete != autoDelete {
+			return memError(amqp.PreconditionFailed, "inequivalent arg for exchange '%s'", name)
+		}
+		return nil
+	}
+	s.exchanges[name] = &memExchange{
+		name:       name,
+		kind:       kind,
+		durable:    durable,
+		autoDelete: autoDelete,
+	}
+	return nil
+}
+
+func (ch *memChannel) QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error) {
+	s := ch.server()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ch.closed {
+		return amqp.Queue{}, amqp.ErrClosed
+	}
+	if name == "" {
+		s.nextID++
+		name = fmt.Sprintf("amq.gen-%d", s.nextID)
+	}
+	if q, ok := s.queues[name]; ok {
+		if err := ch.checkAccessLocked(q); err != nil {
+			return amqp.Queue{}, err
+		}
+		if q.durable != durable || q.autoDelete != autoDelete || q.exclusive != exclusive || !sameArgs(q.args, args) {
+			return amqp.Queue{}, memError(amqp.PreconditionFailed, "inequivalent arg for queue '%s'", name)
+		}
+		return amqp.Queue{Name: name, Messages: len(q.ready), Consumers: len(q.consumers)}, nil
+	}
+	if args["x-queue-type"] == "quorum" && (!durable || autoDelete || exclusive) {
+		return amqp.Queue{}, memError(amqp.PreconditionFailed, "invalid property for quorum queue '%s'", name)
+	}
+	q := &memQueue{
+		name:       name,
+		durable:    durable,
+		autoDelete: autoDelete,
+		exclusive:  exclusive,
+		args:       args,
+	}
+	if exclusive {
+		q.owner = ch.conn
+	}
+	s.queues[name] = q
+	return amqp.Queue{Name: name}, nil
+}
+
+// QueueDeclarePassive reports on an existing queue without declaring it. Like
+// RabbitMQ, it ignores every argument but the name.
+func (ch *memChannel) QueueDeclarePassive(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error) {
+	s := ch.server()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ch.closed {
+		return amqp.Queue{}, amqp.ErrClosed
+	}
+	q, ok := s.queues[name]
+	if !ok {
+		return amqp.Queue{}, memError(amqp.NotFound, "no queue '%s'", name)
+	}
+	if err := ch.checkAccessLocked(q); err != nil {
+		return amqp.Queue{}, err
+	}
+	return amqp.Queue{Name: name, Messages: len(q.ready), Consumers: len(q.consumers)}, nil
+}
+
+func (ch *memChannel) QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error {
+	s := ch.server()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ch.closed {
+		return amqp.ErrClosed
+	}
+	q, ok := s.queues[name]
+	if !ok {
+		return memError(amqp.NotFound, "no queue '%s'", name)
+	}
+	if err := ch.checkAccessLocked(q); err != nil {
+		return err
+	}
+	if exchange == "" {
+		return memError(amqp.AccessRefused, "operation not permitted on the default exchange")
+	}
+	ex, ok := s.exchanges[exchange]
+	if !ok {
+		return memError(amqp.NotFound, "no exchange '%s'", exchange)
+	}
+	b := memBinding{queue: name, key: key}
+	if !slices.Contains(ex.bindings, b) {
+		ex.bindings = append(ex.bindings, b)
+	}
+	return nil
+}
+
+func (ch *memChannel) Qos(prefetchCount, prefetchSize int, global bool) error {
+	s := ch.server()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ch.closed {
+		return amqp.ErrClosed
+	}
+	if prefetchSize != 0 {
+		return memError(amqp.NotImplemented, "prefetch_size is not supported")
+	}
+	ch.prefetch = prefetchCount
+	return nil
+}
+
+func (ch *memChannel) Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error) {
+	s := ch.server()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ch.closed {
+		return nil, amqp.ErrClosed
+	}
+	q, ok := s.queues[queue]
+	if !ok {
+		return nil, memError(amqp.NotFound, "no queue '%s'", queue)
+	}
+	if err := ch.checkAccessLocked(q); err != nil {
+		return nil, err
+	}
+	for _, c := range q.consumers {
+		if c.exclusive || exclusive {
+			return nil, memError(amqp.AccessRefused, "queue '%s' in exclusive use", queue)
+		}
+	}
+	if consumer == "" {
+		s.nextID++
+		consumer = fmt.Sprintf("ctag-%d", s.nextID)
+	}
+	if _, ok := ch.consumers[consumer]; ok {
+		return nil, memError(amqp.NotAllowed, "attempt to reuse consumer tag '%s'", consumer)
+	}
+
+	c := &memConsumer{
+		tag:       consumer,
+		ch:        ch,
+		queue:     q,
+		autoAck:   autoAck,
+		exclusive: exclusive,
+		prefetch:  ch.prefetch,
+		cond:      sync.NewCond(&s.mu),
+		out:       make(chan amqp.Delivery),
+		done:      make(chan struct{}),
+	}
+	ch.consumers[consumer] = c
+	q.consumers = append(q.consumers, c)
+	q.hadConsumer = true
+	go c.run()
+	s.dispatchLocked(q)
+	return c.out, nil
+}
+
+func (ch *memChannel) Cancel(consumer string, noWait bool) error {
+	s := ch.server()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ch.closed {
+		return amqp.ErrClosed
+	}
+	c, ok := ch.consumers[consumer]
+	if !ok {
+		return nil
+	}
+	delete(ch.consumers, consumer)
+
+	// Deliveries the consumer has not handed out yet go back to the queue;
+	// those already handed out stay unacked on the channel.
+	for i := len(c.pending) - 1; i >= 0; i-- {
+		tag := c.pending[i].DeliveryTag
+		if u, ok := ch.unacked[tag]; ok {
+			delete(ch.unacked, tag)
+			s.requeueLocked(u.queue, u.msg)
+		}
+	}
+	s.cancelConsumerLocked(c)
+	q := c.queue
+	if q.autoDelete && len(q.consumers) == 0 {
+		s.deleteQueueLocked(q)
+		return nil
+	}
+	s.dispatchLocked(q)
+	return nil
+}
+
+func (ch *memChannel) Get(queue string, autoAck bool) (amqp.Delivery, bool, error) {
+	s := ch.server()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ch.closed {
+		return amqp.Delivery{}, false, amqp.ErrClosed
+	}
+	q, ok := s.queues[queue]
+	if !ok {
+		return amqp.Delivery{}, false, memError(amqp.NotFound, "no queue '%s'", queue)
+	}
+	if err := ch.checkAccessLocked(q); err != nil {
+		return amqp.Delivery{}, false, err
+	}
+	if len(q.ready) == 0 {
+		return amqp.Delivery{}, false, nil
+	}
+	msg := q.ready[0]
+	q.ready = q.ready[1:]
+	ch.nextTag++
+	if !autoAck {
+		ch.unacked[ch.nextTag] = memUnacked{msg: msg, queue: q}
+	}
+	d := newMemDelivery(ch, "", ch.nextTag, msg)
+	d.MessageCount = uint32(len(q.ready))
+	return d, true, nil
+}
+
+func (ch *memChannel) QueuePurge(name string, noWait bool) (int, error) {
+	s := ch.server()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ch.closed {
+		return 0, amqp.ErrClosed
+	}
+	q, ok := s.queues[name]
+	if !ok {
+		return 0, memError(amqp.NotFound, "no queue '%s'", name)
+	}
+	if err := ch.checkAccessLocked(q); err != nil {
+		return 0, err
+	}
+	n := len(q.ready)
+	q.ready = nil
+	return n, nil
+}
+
+func (ch *memChannel) PublishWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s := ch.server()
+	s.mu.Lock()
+	if ch.closed {
+		s.mu.Unlock()
+		return amqp.ErrClosed
+	}
+	routed, err := s.publishLocked(exchange, key, msg)
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	var seq uint64
+	if ch.confirm {
+		ch.publishSeq++
+		seq = ch.publishSeq
+	}
+	s.mu.Unlock()
+
+	ch.eventsMu.Lock()
+	defer ch.eventsMu.Unlock()
+	if ch.eventsClosed {
+		return nil
+	}
+	if mandatory && routed == 0 {
+		ret := amqp.Return{
+			ReplyCode:       amqp.NoRoute,
+			ReplyText:       "NO_ROUTE",
+			Exchange:        exchange,
+			RoutingKey:      key,
+			ContentType:     msg.ContentType,
+			ContentEncoding: msg.ContentEncoding,
+			DeliveryMode:    msg.DeliveryMode,
+			Priority:        msg.Priority,
+			CorrelationId:   msg.CorrelationId,
+			ReplyTo:         msg.ReplyTo,
+			Expiration:      msg.Expiration,
+			MessageId:       msg.MessageId,
+			Timestamp:       msg.Timestamp,
+			Type:            msg.Type,
+			UserId:          msg.UserId,
+			AppId:           msg.AppId,
+			Headers:         msg.Headers,
+			Body:            msg.Body,
+		}
+		for _, r := range ch.returns {
+			r <- ret
+		}
+	}
+	if seq != 0 {
+		for _, c := range ch.confirms {
+			c <- amqp.Confirmation{DeliveryTag: seq, Ack: true}
+		}
+	}
+	return nil
+}
+
+func (ch *memChannel) Confirm(noWait bool) error {
+	s := ch.server()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ch.closed {
+		return amqp.ErrClosed
+	}
+	ch.confirm = true
+	return nil
+}
+
+func (ch *memChannel) GetNextPublishSeqNo() uint64 {
+	s := ch.server()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !ch.confirm {
+		return 0
+	}
+	return ch.publishSeq + 1
+}
+
+func (ch *memChannel) NotifyPublish(receiver chan amqp.Confirmation) chan amqp.Confirmation {
+	ch.eventsMu.Lock()
+	defer ch.eventsMu.Unlock()
+	if ch.eventsClosed {
+		close(receiver)
+		return receiver
+	}
+	ch.confirms = append(ch.confirms, receiver)
+	return receiver
+}
+
+func (ch *memChannel) NotifyReturn(receiver chan amqp.Return) chan amqp.Return {
+	ch.eventsMu.Lock()
+	defer ch.eventsMu.Unlock()
+	if ch.eventsClosed {
+		close(receiver)
+		return receiver
+	}
+	ch.returns = append(ch.returns, receiver)
+	return receiver
+}
+
+func (ch *memChannel) Close() error {
+	s := ch.server()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ch.closed {
+		return amqp.ErrClosed
+	}
+	s.closeChannelLocked(ch, nil)
+	return nil
+}
+
+func (ch *memChannel) NotifyClose(receiver chan *amqp.Error) chan *amqp.Error {
+	s := ch.server()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ch.closed {
+		close(receiver)
+		return receiver
+	}
+	ch.notify = append(ch.notify, receiver)
+	return receiver
+}
+
+func (ch *memChannel) Ack(tag uint64, multiple bool) error {
+	return ch.settle(tag, multiple, func(s *MemoryServer, u memUnacked) {})
+}
+
+func (ch *memChannel) Nack(tag uint64, multiple bool, requeue bool) error {
+	return ch.settle(tag, multiple, func(s *MemoryServer, u memUnacked) {
+		if requeue {
+			s.requeueLocked(u.queue, u.msg)
+			return
+		}
+		s.deadLetterLocked(u.queue, u.msg, "rejected")
+	})
+}
+
+func (ch *memChannel) Reject(tag uint64, requeue bool) error {
+	return ch.Nack(tag, false, requeue)
+}
+
+func (ch *memChannel) settle(tag uint64, multiple bool, fn func(*MemoryServer, memUnacked)) error {
+	s := ch.server()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ch.closed {
+		return amqp.ErrClosed
+	}
+	tags := []uint64{tag}
+	if multiple {
+		tags = tags[:0]
+		for t := range ch.unacked {
+			if t <= tag {
+				tags = append(tags, t)
+			}
+		}
+		slices.Sort(tags)
+	} else if _, ok := ch.unacked[tag]; !ok {
+		return memError(amqp.PreconditionFailed, "unknown delivery tag %d", tag)
+	}
+
+	touched := map[*memQueue]struct{}{}
+	for i := len(tags) - 1; i >= 0; i-- {
+		u := ch.unacked[tags[i]]
+		delete(ch.unacked, tags[i])
+		if u.consumer != nil {
+			u.consumer.unacked--
+		}
+		fn(s, u)
+		touched[u.queue] = struct{}{}
+	}
+	for q := range touched {
+		s.dispatchLocked(q)
+	}
+	return nil
+}
+
+func (ch *memChannel) checkAccessLocked(q *memQueue) error {
+	if q.exclusive && q.owner != ch.conn {
+		return memError(amqp.ResourceLocked, "cannot obtain exclusive access to locked queue '%s'", q.name)
+	}
+	return nil
+}
+
+func (s *MemoryServer) closeChannelLocked(ch *memChannel, err *amqp.Error) {
+	if ch.closed {
+		return
+	}
+	ch.closed = true
+	for _, c := range ch.consumers {
+		s.cancelConsumerLocked(c)
+	}
+
+	tags := []uint64{}
+	for t := range ch.unacked {
+		tags = append(tags, t)
+	}
+	slices.Sort(tags)
+	touched := map[*memQueue]struct{}{}
+	for i := len(tags) - 1; i >= 0; i-- {
+		u := ch.unacked[tags[i]]
+		s.requeueLocked(u.queue, u.msg)
+		touched[u.queue] = struct{}{}
+	}
+	ch.unacked = map[uint64]memUnacked{}
+	for _, c := range ch.consumers {
+		touched[c.queue] = struct{}{}
+	}
+	ch.consumers = map[string]*memConsumer{}
+
+	for q := range touched {
+		if q.autoDelete && q.hadConsumer && len(q.consumers) == 0 {
+			s.deleteQueueLocked(q)
+			continue
+		}
+		s.dispatchLocked(q)
+	}
+	delete(ch.conn.channels, ch)
+	notifyClosed(ch.notify, err)
+	ch.notify = nil
+	go ch.closeEvents()
+}
+
+func (ch *memChannel) closeEvents() {
+	ch.eventsMu.Lock()
+	defer ch.eventsMu.Unlock()
+	ch.eventsClosed = true
+	for _, c := range ch.confirms {
+		close(c)
+	}
+	for _, r := range ch.returns {
+		close(r)
+	}
+	ch.confirms = nil
+	ch.returns = nil
+}
+
+// notifyClosed mirrors amqp091: receivers get the error, if any, and are then
+// closed. It never blocks the caller, which holds the server lock.
+func notifyClosed(receivers []chan *amqp.Error, err *amqp.Error) {
+	for _, r := range receivers {
+		go func(r chan *amqp.Error) {
+			if err != nil {
+				r <- err
+			}
+			close(r)
+		}(r)
+	}
+}
+
+func (s *MemoryServer) cancelConsumerLocked(c *memConsumer) {
+	if c.cancelled {
+		return
+	}
+	q := c.queue
+	q.consumers = slices.DeleteFunc(q.consumers, func(o *memConsumer) bool {
+		return o == c
+	})
+	c.cancelled = true
+	c.pending = nil
+	close(c.done)
+	c.cond.Broadcast()
+}
+
+func (s *MemoryServer) deleteQueueLocked(q *memQueue) {
+	if s.queues[q.name] != q {
+		return
+	}
+	delete(s.queues, q.name)
+	for _, c := range q.consumers {
+		s.cancelConsumerLocked(c)
+	}
+	for name, ex := range s.exchanges {
+		had := len(ex.bindings) > 0
+		ex.bindings = slices.DeleteFunc(ex.bindings, func(b memBinding) bool {
+			return b.queue == q.name
+		})
+		if ex.autoDelete && had && len(ex.bindings) == 0 {
+			delete(s.exchanges, name)
+		}
+	}
+}
+
+// publishLocked routes msg and reports how many queues received it.
+func (s *MemoryServer) publishLocked(exchange, key string, msg amqp.Publishing) (int, error) {
+	queues, err := s.routeLocked(exchange, key)
+	if err != nil {
+		return 0, err
+	}
+	for _, q := range queues {
+		m := memMessage{
+			exchange: exchange,
+			key:      key,
+			pub:      msg,
+		}
+		if ttl, ok := q.ttl(msg); ok {
+			m.expires = time.Now().Add(ttl)
+			time.AfterFunc(ttl, func() {
+				s.mu.Lock()
+				defer s.mu.Unlock()
+				s.expireLocked(q)
+			})
+		}
+		q.ready = append(q.ready, m)
+		s.dispatchLocked(q)
+		s.enforceMaxLengthLocked(q)
+	}
+	return len(queues), nil
+}
+
+// ttl is the lower of the queue's x-message-ttl and the message's own
+// expiration, if either is set.
+func (q *memQueue) ttl(msg amqp.Publishing) (time.Duration, bool) {
+	ttl, ok := time.Duration(0), false
+	if ms, found := tableInt(q.args["x-message-ttl"]); found {
+		ttl, ok = time.Duration(ms)*time.Millisecond, true
+	}
+	if ms, err := strconv.ParseInt(msg.Expiration, 10, 64); err == nil {
+		if d := time.Duration(ms) * time.Millisecond; !ok || d < ttl {
+			ttl, ok = d, true
+		}
+	}
+	return ttl, ok
+}
+
+func (s *MemoryServer) expireLocked(q *memQueue) {
+	if s.queues[q.name] != q {
+		return
+	}
+	now := time.Now()
+	kept := []memMessage{}
+	expired := []memMessage{}
+	for _, m := range q.ready {
+		if !m.expires.IsZero() && !now.Before(m.expires) {
+			expired = append(expired, m)
+			continue
+		}
+		kept = append(kept, m)
+	}
+	q.ready = kept
+	for _, m := range expired {
+		s.deadLetterLocked(q, m, "expired")
+	}
+}
+
+func (s *MemoryServer) enforceMaxLengthLocked(q *memQueue) {
+	limit, ok := tableInt(q.args["x-max-length"])
+	if !ok {
+		return
+	}
+	for int64(len(q.ready)) > limit {
+		m := q.ready[0]
+		q.ready = q.ready[1:]
+		s.deadLetterLocked(q, m, "maxlen")
+	}
+}
+
+func (s *MemoryServer) routeLocked(exchange, key string) ([]*memQueue, error) {
+	if exchange == "" {
+		if q, ok := s.queues[key]; ok {
+			return []*memQueue{q}, nil
+		}
+		return nil, nil
+	}
+	ex, ok := s.exchanges[exchange]
+	if !ok {
+		return nil, memError(amqp.NotFound, "no exchange '%s'", exchange)
+	}
+	queues := []*memQueue{}
+	for _, b := range ex.bindings {
+		matched := false
+		switch ex.kind {
+		case amqp.ExchangeDirect:
+			matched = b.key == key
+		case amqp.ExchangeTopic:
+			matched = topicMatch(b.key, key)
+		case amqp.ExchangeFanout:
+			matched = true
+		}
+		q, ok := s.queues[b.queue]
+		if matched && ok && !slices.Contains(queues, q) {
+			queues = append(queues, q)
+		}
+	}
+	return queues, nil
+}
+
+func (s *MemoryServer) requeueLocked(q *memQueue, msg memMessage) {
+	if s.queues[q.name] != q {
+		return
+	}
+	msg.redelivered = true
+	q.ready = append([]memMessage{msg}, q.ready...)
+}
+
+func (s *MemoryServer) deadLetterLocked(q *memQueue, msg memMessage, reason string) {
+	dlx, ok := q.args["x-dead-letter-exchange"].(string)
+	if !ok {
+		return
+	}
+	key := msg.key
+	if k, ok := q.args["x-dead-letter-routing-key"].(string); ok {
+		key = k
+	}
+
+	headers := amqp.Table{}
+	for k, v := range msg.pub.Headers {
+		headers[k] = v
+	}
+	deaths := []interface{}{}
+	count := int64(1)
+	if prev, ok := headers["x-death"].([]interface{}); ok {
+		for _, d := range prev {
+			t, ok := d.(amqp.Table)
+			if ok && t["queue"] == q.name && t["reason"] == reason {
+				if n, ok := t["count"].(int64); ok {
+					count = n + 1
+				}
+				continue
+			}
+			deaths = append(deaths, d)
+		}
+	}
+	death := amqp.Table{
+		"count":        count,
+		"reason":       reason,
+		"queue":        q.name,
+		"time":         time.Now(),
+		"exchange":     msg.exchange,
+		"routing-keys": []interface{}{msg.key},
+	}
+	headers["x-death"] = append([]interface{}{death}, deaths...)
+	if _, ok := headers["x-first-death-reason"]; !ok {
+		headers["x-first-death-reason"] = reason
+		headers["x-first-death-queue"] = q.name
+		headers["x-first-death-exchange"] = msg.exchange
+	}
+
+	pub := msg.pub
+	pub.Headers = headers
+	pub.Expiration = ""
+	// Like RabbitMQ, a message dead-lettered to a missing exchange is dropped.
+	s.publishLocked(dlx, key, pub)
+}
+
+func (s *MemoryServer) dispatchLocked(q *memQueue) {
+	if s.queues[q.name] != q {
+		return
+	}
+	for len(q.ready) > 0 {
+		c := q.nextConsumer()
+		if c == nil {
+			return
+		}
+		msg := q.ready[0]
+		q.ready = q.ready[1:]
+		c.ch.nextTag++
+		tag := c.ch.nextTag
+		if !c.autoAck {
+			c.ch.unacked[tag] = memUnacked{msg: msg, queue: q, consumer: c}
+			c.unacked++
+		}
+		c.pending = append(c.pending, newMemDelivery(c.ch, c.tag, tag, msg))
+		c.cond.Signal()
+	}
+}
+
+func (q *memQueue) nextConsumer() *memConsumer {
+	for i := range q.consumers {
+		c := q.consumers[(q.next+i)%len(q.consumers)]
+		if c.autoAck || c.prefetch == 0 || c.unacked < c.prefetch {
+			q.next = (q.next + i + 1) % len(q.consumers)
+			return c
+		}
+	}
+	return nil
+}
+
+func (c *memConsumer) run() {
+	defer close(c.out)
+	mu := c.cond.L
+	for {
+		mu.Lock()
+		for len(c.pending) == 0 && !c.cancelled {
+			c.cond.Wait()
+		}
+		if c.cancelled {
+			mu.Unlock()
+			return
+		}
+		d := c.pending[0]
+		c.pending = c.pending[1:]
+		mu.Unlock()
+
+		select {
+		case c.out <- d:
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func newMemDelivery(ch *memChannel, consumerTag string, tag uint64, msg memMessage) amqp.Delivery {
+	p := msg.pub
+	return amqp.Delivery{
+		Acknowledger:    ch,
+		Headers:         p.Headers,
+		ContentType:     p.ContentType,
+		ContentEncoding: p.ContentEncoding,
+		DeliveryMode:    p.DeliveryMode,
+		Priority:        p.Priority,
+		CorrelationId:   p.CorrelationId,
+		ReplyTo:         p.ReplyTo,
+		Expiration:      p.Expiration,
+		MessageId:       p.MessageId,
+		Timestamp:       p.Timestamp,
+		Type:            p.Type,
+		UserId:          p.UserId,
+		AppId:           p.AppId,
+		ConsumerTag:     consumerTag,
+		DeliveryTag:     tag,
+		Redelivered:     msg.redelivered,
+		Exchange:        msg.exchange,
+		RoutingKey:      msg.key,
+		Body:            p.Body,
+	}
+}
+
+// topicMatch reports whether a topic exchange binding pattern matches key,
+// where '*' stands for exactly one word and '#' for zero or more.
+func topicMatch(pattern, key string) bool {
+	return matchWords(strings.Split(pattern, "."), strings.Split(key, "."))
+}
+
+func matchWords(pattern, key []string) bool {
+	if len(pattern) == 0 {
+		return len(key) == 0
+	}
+	switch pattern[0] {
+	case "#":
+		for i := 0; i <= len(key); i++ {
+			if matchWords(pattern[1:], key[i:]) {
+				return true
+			}
+		}
+		return false
+	case "*":
+		return len(key) > 0 && matchWords(pattern[1:], key[1:])
+	}
+	return len(key) > 0 && pattern[0] == key[0] && matchWords(pattern[1:], key[1:])
+}
+
+func sameArgs(a, b amqp.Table) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func tableInt(v any) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int16:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	}
+	return 0, false
+}
+
+func memError(code int, format string, args ...any) *amqp.Error {
+	return &amqp.Error{
+		Code:   code,
+		Reason: fmt.Sprintf(format, args...),
+		Server: true,
+	}
+}
@@ -0,0 +1,335 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// receiveTimeout bounds how long tests wait for something the memory broker
+// does on another goroutine.
+const receiveTimeout = time.Second
+
+func newMemoryChannel(t *testing.T, s *MemoryServer) Channel {
+	t.Helper()
+	ch, err := s.Connect().Channel()
+	if err != nil {
+		t.Fatalf("could not open channel: %v", err)
+	}
+	return ch
+}
+
+func publish(t *testing.T, ch Channel, exchange, key, body string) {
+	t.Helper()
+	err := ch.PublishWithContext(context.Background(), exchange, key, false, false, amqp.Publishing{Body: []byte(body)})
+	if err != nil {
+		t.Fatalf("could not publish %q: %v", body, err)
+	}
+}
+
+func receive(t *testing.T, deliveries <-chan amqp.Delivery) amqp.Delivery {
+	t.Helper()
+	select {
+	case d, ok := <-deliveries:
+		if !ok {
+			t.Fatal("deliveries closed")
+		}
+		return d
+	case <-time.After(receiveTimeout):
+		t.Fatal("timed out waiting for a delivery")
+	}
+	return amqp.Delivery{}
+}
+
+func getBody(t *testing.T, ch Channel, queue string) (string, bool) {
+	t.Helper()
+	d, ok, err := ch.Get(queue, true)
+	if err != nil {
+		t.Fatalf("could not get from %s: %v", queue, err)
+	}
+	return string(d.Body), ok
+}
+
+func amqpCode(err error) int {
+	var amqpErr *amqp.Error
+	if errors.As(err, &amqpErr) {
+		return amqpErr.Code
+	}
+	return 0
+}
+
+func TestTopicMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		key     string
+		want    bool
+	}{
+		{"army_moves.alice", "army_moves.alice", true},
+		{"army_moves.alice", "army_moves.bob", false},
+		{"army_moves.*", "army_moves.alice", true},
+		{"army_moves.*", "army_moves", false},
+		{"army_moves.*", "army_moves.alice.extra", false},
+		{"*.alice", "war.alice", true},
+		{"game_logs.#", "game_logs", true},
+		{"game_logs.#", "game_logs.alice", true},
+		{"game_logs.#", "game_logs.alice.extra", true},
+		{"game_logs.#", "war.alice", false},
+		{"#", "anything.at.all", true},
+		{"#.alice", "war.alice", true},
+		{"#.alice", "alice", true},
+		{"#.alice", "war.bob", false},
+		{"a.#.z", "a.z", true},
+		{"a.#.z", "a.b.c.z", true},
+		{"a.*.z", "a.z", false},
+	}
+	for _, tt := range tests {
+		got := topicMatch(tt.pattern, tt.key)
+		if got != tt.want {
+			t.Errorf("topicMatch(%q, %q) = %v, want %v", tt.pattern, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestMemoryTopicRouting(t *testing.T) {
+	ch := newMemoryChannel(t, NewMemoryServer())
+	err := ch.ExchangeDeclare("topic", amqp.ExchangeTopic, true, false, false, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for queue, key := range map[string]string{"one": "logs.*", "all": "logs.#"} {
+		if _, err := ch.QueueDeclare(queue, true, false, false, false, nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := ch.QueueBind(queue, key, "topic", false, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	publish(t, ch, "topic", "logs.alice", "first")
+	publish(t, ch, "topic", "logs.alice.war", "second")
+
+	if body, _ := getBody(t, ch, "one"); body != "first" {
+		t.Errorf("one got %q, want first", body)
+	}
+	if body, ok := getBody(t, ch, "one"); ok {
+		t.Errorf("one got %q, want nothing", body)
+	}
+	for _, want := range []string{"first", "second"} {
+		if body, _ := getBody(t, ch, "all"); body != want {
+			t.Errorf("all got %q, want %s", body, want)
+		}
+	}
+}
+
+func TestMemoryAckNackRequeue(t *testing.T) {
+	ch := newMemoryChannel(t, NewMemoryServer())
+	if _, err := ch.QueueDeclare("q", true, false, false, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	deliveries, err := ch.Consume("q", "", false, false, false, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publish(t, ch, "", "q", "msg")
+	d := receive(t, deliveries)
+	if d.Redelivered {
+		t.Error("first delivery is marked redelivered")
+	}
+	if err := d.Nack(false, true); err != nil {
+		t.Fatal(err)
+	}
+
+	d = receive(t, deliveries)
+	if string(d.Body) != "msg" || !d.Redelivered {
+		t.Errorf("got %q redelivered=%v, want msg redelivered", d.Body, d.Redelivered)
+	}
+	if err := d.Ack(false); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Ack(false); amqpCode(err) != amqp.PreconditionFailed {
+		t.Errorf("acking twice: got %v, want PRECONDITION_FAILED", err)
+	}
+
+	if err := ch.Cancel(d.ConsumerTag, false); err != nil {
+		t.Fatal(err)
+	}
+	if body, ok := getBody(t, ch, "q"); ok {
+		t.Errorf("acked message %q is still in the queue", body)
+	}
+}
+
+func TestMemoryUnackedRequeuedOnClose(t *testing.T) {
+	s := NewMemoryServer()
+	ch := newMemoryChannel(t, s)
+	if _, err := ch.QueueDeclare("q", true, false, false, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	publish(t, ch, "", "q", "msg")
+	if _, ok, err := ch.Get("q", false); !ok || err != nil {
+		t.Fatalf("get: ok=%v err=%v", ok, err)
+	}
+	if err := ch.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	other := newMemoryChannel(t, s)
+	d, ok, err := other.Get("q", true)
+	if err != nil || !ok {
+		t.Fatalf("get after close: ok=%v err=%v", ok, err)
+	}
+	if !d.Redelivered {
+		t.Error("message unacked at close is not marked redelivered")
+	}
+}
+
+func TestMemoryDeadLetter(t *testing.T) {
+	ch := newMemoryChannel(t, NewMemoryServer())
+	if err := ch.ExchangeDeclare("dlx", amqp.ExchangeFanout, true, false, false, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ch.QueueDeclare("dlq", true, false, false, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := ch.QueueBind("dlq", "", "dlx", false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ch.QueueDeclare("q", true, false, false, false, amqp.Table{"x-dead-letter-exchange": "dlx"}); err != nil {
+		t.Fatal(err)
+	}
+
+	publish(t, ch, "", "q", "bad")
+	d, _, err := ch.Get("q", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Nack(false, false); err != nil {
+		t.Fatal(err)
+	}
+
+	dead, ok, err := ch.Get("dlq", true)
+	if err != nil || !ok {
+		t.Fatalf("nothing dead-lettered: ok=%v err=%v", ok, err)
+	}
+	if string(dead.Body) != "bad" {
+		t.Errorf("dead-lettered %q, want bad", dead.Body)
+	}
+	if reason := dead.Headers["x-first-death-reason"]; reason != "rejected" {
+		t.Errorf("x-first-death-reason = %v, want rejected", reason)
+	}
+	deaths, _ := dead.Headers["x-death"].([]interface{})
+	if len(deaths) != 1 {
+		t.Fatalf("x-death = %v, want one entry", dead.Headers["x-death"])
+	}
+	if death := deaths[0].(amqp.Table); death["queue"] != "q" || death["count"] != int64(1) {
+		t.Errorf("x-death entry = %v, want queue q count 1", death)
+	}
+}
+
+func TestMemoryMessageTTL(t *testing.T) {
+	ch := newMemoryChannel(t, NewMemoryServer())
+	if _, err := ch.QueueDeclare("dlq", true, false, false, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	args := amqp.Table{
+		"x-message-ttl":             int64(10),
+		"x-dead-letter-exchange":    "",
+		"x-dead-letter-routing-key": "dlq",
+	}
+	if _, err := ch.QueueDeclare("q", true, false, false, false, args); err != nil {
+		t.Fatal(err)
+	}
+	deliveries, err := ch.Consume("dlq", "", true, false, false, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publish(t, ch, "", "q", "stale")
+	d := receive(t, deliveries)
+	if string(d.Body) != "stale" {
+		t.Errorf("expired %q, want stale", d.Body)
+	}
+	if reason := d.Headers["x-first-death-reason"]; reason != "expired" {
+		t.Errorf("x-first-death-reason = %v, want expired", reason)
+	}
+	if body, ok := getBody(t, ch, "q"); ok {
+		t.Errorf("expired message %q is still in the queue", body)
+	}
+}
+
+func TestMemoryExclusiveQueue(t *testing.T) {
+	s := NewMemoryServer()
+	owner := s.Connect()
+	ch, err := owner.Channel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ch.QueueDeclare("mine", false, false, true, false, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	other := newMemoryChannel(t, s)
+	if _, _, err := other.Get("mine", true); amqpCode(err) != amqp.ResourceLocked {
+		t.Errorf("another connection got %v, want RESOURCE_LOCKED", err)
+	}
+
+	if err := owner.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := other.Get("mine", true); amqpCode(err) != amqp.NotFound {
+		t.Errorf("after the owner closed got %v, want NOT_FOUND", err)
+	}
+}
+
+func TestMemoryAutoDeleteQueue(t *testing.T) {
+	ch := newMemoryChannel(t, NewMemoryServer())
+	if _, err := ch.QueueDeclare("temp", false, true, false, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	// It is not deleted before it has had a consumer.
+	if _, _, err := ch.Get("temp", true); err != nil {
+		t.Fatalf("auto-delete queue gone before its first consumer: %v", err)
+	}
+
+	if _, err := ch.Consume("temp", "c", true, false, false, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := ch.Cancel("c", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ch.Get("temp", true); amqpCode(err) != amqp.NotFound {
+		t.Errorf("after its last consumer left got %v, want NOT_FOUND", err)
+	}
+}
+
+func TestMemoryMandatoryReturn(t *testing.T) {
+	ch := newMemoryChannel(t, NewMemoryServer())
+	if err := ch.ExchangeDeclare("direct", amqp.ExchangeDirect, true, false, false, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	returns := ch.NotifyReturn(make(chan amqp.Return, 1))
+
+	err := ch.PublishWithContext(context.Background(), "direct", "nobody", true, false, amqp.Publishing{Body: []byte("lost")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case r := <-returns:
+		if r.ReplyCode != amqp.NoRoute || string(r.Body) != "lost" {
+			t.Errorf("got return %d %q, want NO_ROUTE lost", r.ReplyCode, r.Body)
+		}
+	case <-time.After(receiveTimeout):
+		t.Fatal("unroutable mandatory message was not returned")
+	}
+
+	// Messages that are not mandatory are dropped silently.
+	publish(t, ch, "direct", "nobody", "dropped")
+	select {
+	case r := <-returns:
+		t.Errorf("got return for a message that was not mandatory: %q", r.Body)
+	default:
+	}
+}
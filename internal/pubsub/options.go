@@ -0,0 +1,212 @@
+package pubsub
+
+import (
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const defaultPrefetch = 10
+
+type SubscribeOption func(*subscribeConfig)
+
+type subscribeConfig struct {
+	onError     func(error)
+	concurrency int
+	prefetch    int
+	orderingKey func(amqp.Delivery) string
+
+	defaultCodec  Codec
+	schemaVersion int
+	upcasters     map[int]Upcaster
+	retry         *RetryPolicy
+
+	deadLetterExchange string
+	extraArgs          amqp.Table
+	consumerTag        string
+	bindings           []string
+	messageTTL         time.Duration
+	maxLength          int
+	quorum             bool
+	exclusive          *bool
+}
+
+func newSubscribeConfig(opts []SubscribeOption) subscribeConfig {
+	cfg := subscribeConfig{
+		concurrency:        1,
+		deadLetterExchange: routing.ExchangePerilDLX,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.prefetch == 0 {
+		cfg.prefetch = max(defaultPrefetch, cfg.concurrency)
+	}
+	return cfg
+}
+
+func (cfg subscribeConfig) queueArgs() amqp.Table {
+	args := amqp.Table{}
+	if cfg.deadLetterExchange != "" {
+		args["x-dead-letter-exchange"] = cfg.deadLetterExchange
+	}
+	if cfg.messageTTL > 0 {
+		args["x-message-ttl"] = cfg.messageTTL.Milliseconds()
+	}
+	if cfg.maxLength > 0 {
+		args["x-max-length"] = int64(cfg.maxLength)
+	}
+	if cfg.quorum {
+		args["x-queue-type"] = "quorum"
+	}
+	for k, v := range cfg.extraArgs {
+		args[k] = v
+	}
+	return args
+}
+
+// OnError registers a callback for messages the subscription could not
+// process, such as payloads that fail to decode. It is called from the
+// consumer goroutine.
+func OnError(fn func(error)) SubscribeOption {
+	return func(cfg *subscribeConfig) {
+		cfg.onError = fn
+	}
+}
+
+// WithConcurrency handles up to n deliveries at once, each on its own worker
+// goroutine. Every delivery is still acked individually.
+func WithConcurrency(n int) SubscribeOption {
+	return func(cfg *subscribeConfig) {
+		cfg.concurrency = max(n, 1)
+	}
+}
+
+// WithPrefetch sets how many unacked deliveries the broker may send ahead.
+// It defaults to 10, or the concurrency if that is higher.
+func WithPrefetch(n int) SubscribeOption {
+	return func(cfg *subscribeConfig) {
+		cfg.prefetch = n
+	}
+}
+
+// WithOrderingKey makes deliveries that share a key run one at a time, in
+// the order they arrived, even with several workers.
+func WithOrderingKey(key func(amqp.Delivery) string) SubscribeOption {
+	return func(cfg *subscribeConfig) {
+		cfg.orderingKey = key
+	}
+}
+
+// ByRoutingKey is an ordering key for WithOrderingKey, e.g. to keep all of
+// one player's game logs in order. Like the Envelope, it uses the key the
+// message was first published with, which retried messages carry in a
+// header.
+func ByRoutingKey(d amqp.Delivery) string {
+	return envelopeFromDelivery(d).RoutingKey
+}
+
+// WithDeadLetter sets the exchange rejected and undecodable messages are sent
+// to. It defaults to peril_dlx; an empty name turns dead-lettering off.
+func WithDeadLetter(exchange string) SubscribeOption {
+	return func(cfg *subscribeConfig) {
+		cfg.deadLetterExchange = exchange
+	}
+}
+
+// WithQueueArgs adds raw x-arguments to the queue declaration. They take
+// precedence over the ones set by other options.
+func WithQueueArgs(args amqp.Table) SubscribeOption {
+	return func(cfg *subscribeConfig) {
+		if cfg.extraArgs == nil {
+			cfg.extraArgs = amqp.Table{}
+		}
+		for k, v := range args {
+			cfg.extraArgs[k] = v
+		}
+	}
+}
+
+func WithConsumerTag(tag string) SubscribeOption {
+	return func(cfg *subscribeConfig) {
+		cfg.consumerTag = tag
+	}
+}
+
+// WithMultipleBindings binds the queue with these routing keys in addition
+// to the one passed to DeclareAndBind or Subscribe.
+func WithMultipleBindings(keys ...string) SubscribeOption {
+	return func(cfg *subscribeConfig) {
+		cfg.bindings = append(cfg.bindings, keys...)
+	}
+}
+
+func WithMessageTTL(ttl time.Duration) SubscribeOption {
+	return func(cfg *subscribeConfig) {
+		cfg.messageTTL = ttl
+	}
+}
+
+// WithMaxLength caps the number of ready messages in the queue; the oldest
+// are dropped (and dead-lettered) first.
+func WithMaxLength(n int) SubscribeOption {
+	return func(cfg *subscribeConfig) {
+		cfg.maxLength = n
+	}
+}
+
+// WithQuorumQueue declares a replicated quorum queue. Only Durable queues
+// can be quorum queues.
+func WithQuorumQueue() SubscribeOption {
+	return func(cfg *subscribeConfig) {
+		cfg.quorum = true
+	}
+}
+
+// WithExclusive overrides whether the queue is exclusive to this connection,
+// which otherwise follows the SimpleQueueType.
+func WithExclusive(exclusive bool) SubscribeOption {
+	return func(cfg *subscribeConfig) {
+		cfg.exclusive = &exclusive
+	}
+}
+
+// WithDefaultCodec decodes messages that carry no content type with codec.
+// Messages that do carry one always use the codec registered for it.
+func WithDefaultCodec(codec Codec) SubscribeOption {
+	return func(cfg *subscribeConfig) {
+		cfg.defaultCodec = codec
+	}
+}
+
+// WithSchemaVersion sets the newest schema version the subscriber
+// understands. It defaults to the message type's SchemaVersion. Newer
+// messages, and older ones no upcaster covers, are dead-lettered like
+// undecodable ones.
+func WithSchemaVersion(version int) SubscribeOption {
+	return func(cfg *subscribeConfig) {
+		cfg.schemaVersion = version
+	}
+}
+
+// WithUpcaster converts messages of schema version from into version from+1
+// before they are decoded. Upcasters chain, so an old message can pass
+// through several of them.
+func WithUpcaster(from int, up Upcaster) SubscribeOption {
+	return func(cfg *subscribeConfig) {
+		if cfg.upcasters == nil {
+			cfg.upcasters = map[int]Upcaster{}
+		}
+		cfg.upcasters[from] = up
+	}
+}
+
+// WithRetry declares the retry queues for policy and enables NackRetryLater.
+// Without it, NackRetryLater rejects the message like NackDiscard.
+func WithRetry(policy RetryPolicy) SubscribeOption {
+	return func(cfg *subscribeConfig) {
+		policy = policy.withDefaults()
+		cfg.retry = &policy
+	}
+}
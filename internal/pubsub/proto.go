@@ -0,0 +1,52 @@
+package pubsub
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// ProtoMarshaler is implemented by Go types that have a protobuf form on the
+// wire, such as routing.GameLog.
+type ProtoMarshaler interface {
+	ToProto() proto.Message
+}
+
+// ProtoUnmarshaler is implemented by pointers to Go types that can be filled
+// from their protobuf form. NewProto returns an empty message for the codec
+// to decode into.
+type ProtoUnmarshaler interface {
+	NewProto() proto.Message
+	FromProto(m proto.Message) error
+}
+
+var Protobuf Codec = protobufCodec{}
+
+type protobufCodec struct{}
+
+func (protobufCodec) ContentType() string { return "application/x-protobuf" }
+
+func (protobufCodec) Marshal(v any) ([]byte, error) {
+	switch v := v.(type) {
+	case proto.Message:
+		return proto.Marshal(v)
+	case ProtoMarshaler:
+		return proto.Marshal(v.ToProto())
+	}
+	return nil, fmt.Errorf("cannot encode %T as protobuf", v)
+}
+
+func (protobufCodec) Unmarshal(data []byte, v any) error {
+	switch v := v.(type) {
+	case proto.Message:
+		return proto.Unmarshal(data, v)
+	case ProtoUnmarshaler:
+		m := v.NewProto()
+		err := proto.Unmarshal(data, m)
+		if err != nil {
+			return err
+		}
+		return v.FromProto(m)
+	}
+	return fmt.Errorf("cannot decode protobuf into %T", v)
+}
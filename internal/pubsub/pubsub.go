@@ -1,11 +1,8 @@
 package pubsub
 
 import (
-	"bytes"
 	"context"
-	"encoding/gob"
-	"encoding/json"
-	"fmt"
+	"errors"
 	"time"
 
 	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
@@ -25,134 +22,163 @@ const (
 	Ack Acktype = iota
 	NackRequeue
 	NackDiscard
+	// NackRetryLater hands the message back after a delay set by the
+	// subscription's RetryPolicy (see WithRetry), rather than straight away.
+	NackRetryLater
 )
 
-func PublishJSON[T any](ch *amqp.Channel, exchange, key string, val T) error {
-	body, err := json.Marshal(val)
+// Publish encodes val with codec and labels the message with the codec's
+// content type, so subscribers know how to decode it. The message is wrapped
+// in an Envelope; see WithSender and WithCorrelationID.
+func Publish[T any](ctx context.Context, ch Publisher, codec Codec, exchange, key string, val T) error {
+	body, err := codec.Marshal(val)
 	if err != nil {
 		return err
 	}
 
-	err = ch.PublishWithContext(context.Background(), exchange, key, false, false, amqp.Publishing{
-		ContentType: "application/json",
+	pub := amqp.Publishing{
+		ContentType: codec.ContentType(),
 		Body:        body,
-	})
+	}
+	newEnvelope(ctx, val).apply(&pub)
+	err = ch.PublishWithContext(ctx, exchange, key, false, false, pub)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+func PublishJSON[T any](ctx context.Context, ch Publisher, exchange, key string, val T) error {
+	return Publish(ctx, ch, JSON, exchange, key, val)
+}
+
+// QueueSpec is a queue declaration as DeclareAndBind and Subscribe make it.
+type QueueSpec struct {
+	Name       string
+	Durable    bool
+	AutoDelete bool
+	Exclusive  bool
+	Args       amqp.Table
+}
+
+func (q QueueSpec) Declare(ch Subscriber) (amqp.Queue, error) {
+	return ch.QueueDeclare(q.Name, q.Durable, q.AutoDelete, q.Exclusive, false, q.Args)
+}
+
+// QueueSpecs returns the queues Subscribe declares for queueName with opts:
+// the queue itself, followed by its retry queues if opts include WithRetry.
+func QueueSpecs(queueName string, queueType SimpleQueueType, opts ...SubscribeOption) ([]QueueSpec, error) {
+	cfg := newSubscribeConfig(opts)
+	if cfg.quorum && queueType != Durable {
+		return nil, errors.New("quorum queues must be durable")
+	}
+
+	q := QueueSpec{
+		Name: queueName,
+		Args: cfg.queueArgs(),
+	}
+	switch queueType {
+	case Durable:
+		q.Durable = true
+	case Transient:
+		q.AutoDelete = true
+		q.Exclusive = true
+	}
+	if cfg.exclusive != nil {
+		q.Exclusive = *cfg.exclusive
+	}
+
+	specs := []QueueSpec{q}
+	if cfg.retry != nil {
+		specs = append(specs, retryQueueSpecs(queueName, q.Durable, *cfg.retry)...)
+	}
+	return specs, nil
+}
+
 func DeclareAndBind(
-	conn *amqp.Connection,
+	ctx context.Context,
+	conn Broker,
 	exchange,
 	queueName,
 	key string,
 	queueType SimpleQueueType,
-) (*amqp.Channel, amqp.Queue, error) {
-	ch, err := conn.Channel()
-	if err != nil {
+	opts ...SubscribeOption,
+) (Channel, amqp.Queue, error) {
+	if err := ctx.Err(); err != nil {
 		return nil, amqp.Queue{}, err
 	}
-
-	queueDurable := false
-	autoDelete := false
-	exclusive := false
-
-	switch queueType {
-	case Durable:
-		queueDurable = true
-	case Transient:
-		autoDelete = true
-		exclusive = true
+	cfg := newSubscribeConfig(opts)
+	specs, err := QueueSpecs(queueName, queueType, opts...)
+	if err != nil {
+		return nil, amqp.Queue{}, err
 	}
 
-	queue, err := ch.QueueDeclare(queueName, queueDurable, autoDelete, exclusive, false, amqp.Table{
-		"x-dead-letter-exchange": "peril_dlx",
-	})
+	ch, err := conn.Channel()
 	if err != nil {
 		return nil, amqp.Queue{}, err
 	}
 
-	err = ch.QueueBind(queueName, key, exchange, false, nil)
+	queue, err := specs[0].Declare(ch)
 	if err != nil {
+		ch.Close()
 		return nil, amqp.Queue{}, err
 	}
 
+	for _, k := range append([]string{key}, cfg.bindings...) {
+		err = ch.QueueBind(queueName, k, exchange, false, nil)
+		if err != nil {
+			ch.Close()
+			return nil, amqp.Queue{}, err
+		}
+	}
+
 	return ch, queue, nil
 }
 
+// SubscribeJSON is Subscribe for queues whose publishers have historically
+// sent JSON: messages without a content type are decoded as JSON.
 func SubscribeJSON[T any](
-	conn *amqp.Connection,
+	ctx context.Context,
+	conn Broker,
 	exchange,
 	queueName,
 	key string,
 	queueType SimpleQueueType,
-	handler func(T) Acktype,
-) error {
-	return subscribe(
-		conn,
-		exchange,
-		queueName,
-		key,
-		queueType,
-		handler,
-		func(v []byte) (T, error) {
-			var res T
-			err := json.Unmarshal(v, &res)
-			return res, err
-		},
-	)
+	handler func(context.Context, T) Acktype,
+	opts ...SubscribeOption,
+) (*Subscription, error) {
+	opts = append([]SubscribeOption{WithDefaultCodec(JSON)}, opts...)
+	return Subscribe(ctx, conn, exchange, queueName, key, queueType, handler, opts...)
 }
 
+// SubscribeGOB is Subscribe with GOB as the codec for messages without a
+// content type.
 func SubscribeGOB[T any](
-	conn *amqp.Connection,
+	ctx context.Context,
+	conn Broker,
 	exchange,
 	queueName,
 	key string,
 	queueType SimpleQueueType,
-	handler func(T) Acktype,
-) error {
-	return subscribe(
-		conn,
-		exchange,
-		queueName,
-		key,
-		queueType,
-		handler,
-		func(v []byte) (T, error) {
-			buf := bytes.NewBuffer(v)
-			dec := gob.NewDecoder(buf)
-			var res T
-			err := dec.Decode(&res)
-			return res, err
-		},
-	)
+	handler func(context.Context, T) Acktype,
+	opts ...SubscribeOption,
+) (*Subscription, error) {
+	opts = append([]SubscribeOption{WithDefaultCodec(GOB)}, opts...)
+	return Subscribe(ctx, conn, exchange, queueName, key, queueType, handler, opts...)
 }
 
-func PublishGOB[T any](ch *amqp.Channel, exchange, key string, val T) error {
-	var buf bytes.Buffer
-	enc := gob.NewEncoder(&buf)
-	err := enc.Encode(val)
-	if err != nil {
-		return err
-	}
-	body := buf.Bytes()
+func PublishGOB[T any](ctx context.Context, ch Publisher, exchange, key string, val T) error {
+	return Publish(ctx, ch, GOB, exchange, key, val)
+}
 
-	err = ch.PublishWithContext(context.Background(), exchange, key, false, false, amqp.Publishing{
-		ContentType: "application/gob",
-		Body:        body,
-	})
-	if err != nil {
-		return err
-	}
-	return nil
+func PublishProto[T any](ctx context.Context, ch Publisher, exchange, key string, val T) error {
+	return Publish(ctx, ch, Protobuf, exchange, key, val)
 }
 
-func PublishGameLog(ch *amqp.Channel, user, val string) error {
-	key := fmt.Sprintf("%s.%s", routing.GameLogSlug, user)
+func PublishGameLog(ctx context.Context, ch Publisher, user, val string) error {
+	key := routing.GameLogKey(user)
 
-	err := PublishGOB(ch, routing.ExchangePerilTopic, key, routing.GameLog{
+	err := PublishProto(WithSender(ctx, user), ch, routing.ExchangePerilTopic, key, routing.GameLog{
 		Message:     val,
 		Username:    user,
 		CurrentTime: time.Now(),
@@ -163,48 +189,112 @@ func PublishGameLog(ch *amqp.Channel, user, val string) error {
 	return nil
 }
 
-func subscribe[T any](
-	conn *amqp.Connection,
+// Subscribe declares and binds the queue and hands every message to handler,
+// decoding it with the codec registered for the message's content type.
+// handler's context carries the message's Envelope and is not cancelled
+// with ctx, so a handler can finish while the subscription drains.
+func Subscribe[T any](
+	ctx context.Context,
+	conn Broker,
 	exchange,
 	queueName,
 	key string,
 	simpleQueueType SimpleQueueType,
-	handler func(T) Acktype,
-	unmarshaller func([]byte) (T, error),
-) error {
-	ch, _, err := DeclareAndBind(conn, exchange, queueName, key, simpleQueueType)
+	handler func(context.Context, T) Acktype,
+	opts ...SubscribeOption,
+) (*Subscription, error) {
+	cfg := newSubscribeConfig(opts)
+	if cfg.schemaVersion == 0 {
+		var zero T
+		cfg.schemaVersion = schemaVersionOf(zero)
+	}
+
+	ch, _, err := DeclareAndBind(ctx, conn, exchange, queueName, key, simpleQueueType, opts...)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	err = ch.Qos(10, 0, false)
+	specs, _ := QueueSpecs(queueName, simpleQueueType, opts...)
+	for _, retryQueue := range specs[1:] {
+		_, err = retryQueue.Declare(ch)
+		if err != nil {
+			ch.Close()
+			return nil, err
+		}
+	}
+
+	err = ch.Qos(cfg.prefetch, 0, false)
 	if err != nil {
-		return err
+		ch.Close()
+		return nil, err
 	}
 
-	del, err := ch.Consume(queueName, "", false, false, false, false, nil)
+	tag := cfg.consumerTag
+	if tag == "" {
+		tag = newConsumerTag()
+	}
+	del, err := ch.Consume(queueName, tag, false, false, false, false, nil)
 	if err != nil {
-		return err
+		ch.Close()
+		return nil, err
 	}
 
-	var res error
-	res = nil
-	go func() {
-		for mess := range del {
-			v, err := unmarshaller(mess.Body)
-			res = err
-			at := handler(v)
-
-			switch at {
-			case Ack:
-				mess.Ack(false)
-			case NackRequeue:
-				mess.Nack(false, true)
-			case NackDiscard:
+	handlerCtx := context.WithoutCancel(ctx)
+	sub := newSubscription(ch, tag)
+	go sub.run(del, cfg, func(mess amqp.Delivery) {
+		mess, err := cfg.upcast(mess)
+		var v T
+		if err == nil {
+			v, err = decode[T](mess, cfg.defaultCodec)
+		}
+		if err != nil {
+			decodeErr := &DecodeError{
+				Queue:       queueName,
+				Exchange:    mess.Exchange,
+				RoutingKey:  mess.RoutingKey,
+				ContentType: mess.ContentType,
+				Err:         err,
+			}
+			rejectPoison(ch, cfg.deadLetterExchange, mess, decodeErr)
+			if cfg.onError != nil {
+				cfg.onError(decodeErr)
+			}
+			return
+		}
+		at := handler(contextWithEnvelope(handlerCtx, envelopeFromDelivery(mess)), v)
+
+		switch at {
+		case Ack:
+			mess.Ack(false)
+		case NackRequeue:
+			mess.Nack(false, true)
+		case NackDiscard:
+			mess.Nack(false, false)
+		case NackRetryLater:
+			if cfg.retry == nil {
 				mess.Nack(false, false)
+				break
 			}
+			err := retryLater(ch, queueName, *cfg.retry, mess)
+			if err != nil && cfg.onError != nil {
+				cfg.onError(err)
+			}
+		}
+	})
+	go sub.drainOnDone(ctx)
+	return sub, nil
+}
 
+func decode[T any](d amqp.Delivery, fallback Codec) (T, error) {
+	var v T
+	codec := fallback
+	if d.ContentType != "" || codec == nil {
+		c, err := CodecFor(d.ContentType)
+		if err != nil {
+			return v, err
 		}
-	}()
-	return res
+		codec = c
+	}
+	err := codec.Unmarshal(d.Body, &v)
+	return v, err
 }
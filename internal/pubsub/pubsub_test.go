@@ -0,0 +1,121 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+type testMessage struct {
+	N int
+}
+
+// newWorkExchange declares the direct exchange, and the dlx exchange with
+// dlq bound to it, and returns dlq's deliveries.
+func newWorkExchange(t *testing.T, ch Channel) <-chan amqp.Delivery {
+	t.Helper()
+	if err := ch.ExchangeDeclare("direct", amqp.ExchangeDirect, true, false, false, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := ch.ExchangeDeclare("dlx", amqp.ExchangeFanout, true, false, false, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ch.QueueDeclare("dlq", true, false, false, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := ch.QueueBind("dlq", "", "dlx", false, nil); err != nil {
+		t.Fatal(err)
+	}
+	dead, err := ch.Consume("dlq", "", true, false, false, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dead
+}
+
+// subscribeWork subscribes handled to queue work, bound to the direct
+// exchange with key work, and closes the subscription when the test ends.
+func subscribeWork(t *testing.T, s *MemoryServer, handled chan<- testMessage, opts ...SubscribeOption) {
+	t.Helper()
+	opts = append([]SubscribeOption{WithDeadLetter("dlx")}, opts...)
+	sub, err := SubscribeJSON(context.Background(), s.Connect(), "direct", "work", "work", Durable,
+		func(_ context.Context, m testMessage) Acktype {
+			handled <- m
+			return Ack
+		}, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sub.Close() })
+}
+
+func publishRaw(t *testing.T, ch Channel, contentType, body string) {
+	t.Helper()
+	err := ch.PublishWithContext(context.Background(), "direct", "work", false, false, amqp.Publishing{
+		ContentType: contentType,
+		MessageId:   "poison",
+		Body:        []byte(body),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func receiveValue[T any](t *testing.T, c <-chan T) T {
+	t.Helper()
+	select {
+	case v := <-c:
+		return v
+	case <-time.After(receiveTimeout):
+		t.Fatal("timed out waiting")
+	}
+	var zero T
+	return zero
+}
+
+func TestSubscribeDeadLettersUndecodable(t *testing.T) {
+	s := NewMemoryServer()
+	ch := newMemoryChannel(t, s)
+	dead := newWorkExchange(t, ch)
+	handled := make(chan testMessage, 1)
+	errs := make(chan error, 1)
+	subscribeWork(t, s, handled, OnError(func(err error) { errs <- err }))
+
+	publishRaw(t, ch, JSON.ContentType(), "{not json")
+	d := receive(t, dead)
+	if string(d.Body) != "{not json" || d.MessageId != "poison" {
+		t.Errorf("dead-lettered %q with ID %q, want the message as it was published", d.Body, d.MessageId)
+	}
+	if reason, _ := d.Headers[HeaderDecodeError].(string); reason == "" {
+		t.Error("dead letter has no decode error")
+	}
+	if d.Headers[HeaderOriginalExchange] != "direct" || d.Headers[HeaderOriginalRoutingKey] != "work" || d.Headers[HeaderOriginalQueue] != "work" {
+		t.Errorf("original exchange, key and queue = %v %v %v, want direct work work",
+			d.Headers[HeaderOriginalExchange], d.Headers[HeaderOriginalRoutingKey], d.Headers[HeaderOriginalQueue])
+	}
+	if dl := newDeadLetter(d); dl.Exchange != "direct" || dl.RoutingKey != "work" {
+		t.Errorf("a replay would go to %s with key %q, want direct with key work", dl.Exchange, dl.RoutingKey)
+	}
+
+	var decodeErr *DecodeError
+	if err := receiveValue(t, errs); !errors.As(err, &decodeErr) {
+		t.Fatalf("OnError got %v, want a DecodeError", err)
+	}
+	if decodeErr.Queue != "work" || decodeErr.Exchange != "direct" || decodeErr.RoutingKey != "work" || decodeErr.ContentType != JSON.ContentType() {
+		t.Errorf("got %+v, want the queue, exchange, key and content type of the message", decodeErr)
+	}
+
+	// The subscription carries on with the next message.
+	if err := PublishJSON(context.Background(), ch, "direct", "work", testMessage{N: 7}); err != nil {
+		t.Fatal(err)
+	}
+	if m := receiveValue(t, handled); m.N != 7 {
+		t.Errorf("handled %+v, want N 7", m)
+	}
+	if n, _ := ch.QueuePurge("work", false); n != 0 {
+		t.Errorf("%d messages left in work", n)
+	}
+}
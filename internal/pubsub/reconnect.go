@@ -0,0 +1,587 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+var ErrNotConnected = errors.New("not connected to the broker, reconnecting")
+
+type ReconnectOptions struct {
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// OnReconnecting is called before every reconnection attempt with the
+	// error that caused the previous connection or attempt to fail.
+	OnReconnecting func(attempt int, err error)
+	OnReconnected  func()
+}
+
+// ManagedBroker is a Broker that survives broker restarts. It watches the
+// underlying connection and, when it drops, dials again with exponential
+// backoff and replays every declaration, binding, Qos and consumer made
+// through its channels. Delivery channels returned by Consume stay open
+// across reconnects.
+type ManagedBroker struct {
+	dial func() (Broker, error)
+	opts ReconnectOptions
+	done chan struct{}
+
+	mu       sync.Mutex
+	conn     Broker
+	channels map[*managedChannel]struct{}
+	notify   []chan *amqp.Error
+	closed   bool
+}
+
+type managedChannel struct {
+	broker *ManagedBroker
+
+	mu        sync.Mutex
+	ch        Channel
+	topology  []func(Channel) error
+	consumers []*managedConsumer
+	confirms  []chan amqp.Confirmation
+	returns   []chan amqp.Return
+	bridges   sync.WaitGroup
+	notify    []chan *amqp.Error
+	closed    bool
+}
+
+type managedConsumer struct {
+	queue     string
+	tag       string
+	autoAck   bool
+	exclusive bool
+	noLocal   bool
+	args      amqp.Table
+	out       chan amqp.Delivery
+	done      chan struct{}
+	wg        sync.WaitGroup
+}
+
+func NewManagedBroker(dial func() (Broker, error), opts ReconnectOptions) (*ManagedBroker, error) {
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = 500 * time.Millisecond
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = 30 * time.Second
+	}
+
+	conn, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	b := &ManagedBroker{
+		dial:     dial,
+		opts:     opts,
+		done:     make(chan struct{}),
+		conn:     conn,
+		channels: map[*managedChannel]struct{}{},
+	}
+	go b.watch(conn)
+	return b, nil
+}
+
+func DialManaged(url string, opts ReconnectOptions) (*ManagedBroker, error) {
+	return NewManagedBroker(func() (Broker, error) {
+		return Dial(url)
+	}, opts)
+}
+
+func (b *ManagedBroker) Channel() (Channel, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, amqp.ErrClosed
+	}
+	if b.conn == nil {
+		return nil, ErrNotConnected
+	}
+	ch, err := b.conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+	mc := &managedChannel{
+		broker: b,
+		ch:     ch,
+	}
+	b.channels[mc] = struct{}{}
+	mc.bridge(ch)
+	go mc.watch(ch)
+	return mc, nil
+}
+
+func (b *ManagedBroker) NotifyClose(receiver chan *amqp.Error) chan *amqp.Error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(receiver)
+		return receiver
+	}
+	b.notify = append(b.notify, receiver)
+	return receiver
+}
+
+func (b *ManagedBroker) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return amqp.ErrClosed
+	}
+	b.closed = true
+	close(b.done)
+	conn := b.conn
+	channels := []*managedChannel{}
+	for mc := range b.channels {
+		channels = append(channels, mc)
+	}
+	notify := b.notify
+	b.mu.Unlock()
+
+	for _, mc := range channels {
+		mc.Close()
+	}
+	var err error
+	if conn != nil {
+		err = conn.Close()
+	}
+	for _, r := range notify {
+		close(r)
+	}
+	return err
+}
+
+func (b *ManagedBroker) watch(conn Broker) {
+	for {
+		var cause error = amqp.ErrClosed
+		if err, ok := <-conn.NotifyClose(make(chan *amqp.Error, 1)); ok && err != nil {
+			cause = err
+		}
+
+		b.mu.Lock()
+		if b.closed {
+			b.mu.Unlock()
+			return
+		}
+		b.conn = nil
+		b.mu.Unlock()
+
+		conn = b.reconnect(cause)
+		if conn == nil {
+			return
+		}
+	}
+}
+
+func (b *ManagedBroker) reconnect(cause error) Broker {
+	backoff := b.opts.MinBackoff
+	for attempt := 1; ; attempt++ {
+		if b.opts.OnReconnecting != nil {
+			b.opts.OnReconnecting(attempt, cause)
+		}
+		select {
+		case <-b.done:
+			return nil
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, b.opts.MaxBackoff)
+
+		conn, err := b.dial()
+		if err != nil {
+			cause = err
+			continue
+		}
+		ok, err := b.restore(conn)
+		if err != nil {
+			conn.Close()
+			cause = err
+			continue
+		}
+		if !ok {
+			conn.Close()
+			return nil
+		}
+		if b.opts.OnReconnected != nil {
+			b.opts.OnReconnected()
+		}
+		return conn
+	}
+}
+
+// restore reopens every live channel on conn. It reports false if the broker
+// was closed while reconnecting.
+func (b *ManagedBroker) restore(conn Broker) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return false, nil
+	}
+	for mc := range b.channels {
+		if err := mc.reopen(conn, nil); err != nil {
+			return true, err
+		}
+	}
+	b.conn = conn
+	return true, nil
+}
+
+// watch reopens the channel after a channel-level exception while the
+// connection itself stays up. Connection failures are handled by the broker.
+func (mc *managedChannel) watch(ch Channel) {
+	err, ok := <-ch.NotifyClose(make(chan *amqp.Error, 1))
+	if !ok || err == nil {
+		return
+	}
+	mc.broker.mu.Lock()
+	conn := mc.broker.conn
+	mc.broker.mu.Unlock()
+	if conn != nil {
+		mc.reopen(conn, ch)
+	}
+}
+
+// reopen replaces the underlying channel. When stale is set the channel is
+// only replaced if it is still the current one.
+func (mc *managedChannel) reopen(conn Broker, stale Channel) error {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if mc.closed || (stale != nil && mc.ch != stale) {
+		return nil
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	for _, op := range mc.topology {
+		if err := op(ch); err != nil {
+			ch.Close()
+			return err
+		}
+	}
+	for _, c := range mc.consumers {
+		deliveries, err := ch.Consume(c.queue, c.tag, c.autoAck, c.exclusive, c.noLocal, false, c.args)
+		if err != nil {
+			ch.Close()
+			return err
+		}
+		c.forward(deliveries)
+	}
+	mc.bridge(ch)
+	mc.ch = ch
+	go mc.watch(ch)
+	return nil
+}
+
+func (mc *managedChannel) current() (Channel, error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if mc.closed {
+		return nil, amqp.ErrClosed
+	}
+	return mc.ch, nil
+}
+
+// record runs op on the current channel and, if it succeeds, keeps it to be
+// replayed after a reconnect.
+func (mc *managedChannel) record(op func(Channel) error) error {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if mc.closed {
+		return amqp.ErrClosed
+	}
+	if err := op(mc.ch); err != nil {
+		return err
+	}
+	mc.topology = append(mc.topology, op)
+	return nil
+}
+
+func (mc *managedChannel) ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error {
+	return mc.record(func(ch Channel) error {
+		return ch.ExchangeDeclare(name, kind, durable, autoDelete, internal, noWait, args)
+	})
+}
+
+func (mc *managedChannel) QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error) {
+	var queue amqp.Queue
+	err := mc.record(func(ch Channel) error {
+		q, err := ch.QueueDeclare(name, durable, autoDelete, exclusive, noWait, args)
+		queue = q
+		return err
+	})
+	return queue, err
+}
+
+// QueueDeclarePassive is not replayed after a reconnect: it only looks the
+// queue up.
+func (mc *managedChannel) QueueDeclarePassive(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error) {
+	ch, err := mc.current()
+	if err != nil {
+		return amqp.Queue{}, err
+	}
+	return ch.QueueDeclarePassive(name, durable, autoDelete, exclusive, noWait, args)
+}
+
+func (mc *managedChannel) QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error {
+	return mc.record(func(ch Channel) error {
+		return ch.QueueBind(name, key, exchange, noWait, args)
+	})
+}
+
+func (mc *managedChannel) Qos(prefetchCount, prefetchSize int, global bool) error {
+	return mc.record(func(ch Channel) error {
+		return ch.Qos(prefetchCount, prefetchSize, global)
+	})
+}
+
+func (mc *managedChannel) Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if mc.closed {
+		return nil, amqp.ErrClosed
+	}
+	deliveries, err := mc.ch.Consume(queue, consumer, autoAck, exclusive, noLocal, noWait, args)
+	if err != nil {
+		return nil, err
+	}
+	c := &managedConsumer{
+		queue:     queue,
+		tag:       consumer,
+		autoAck:   autoAck,
+		exclusive: exclusive,
+		noLocal:   noLocal,
+		args:      args,
+		out:       make(chan amqp.Delivery),
+		done:      make(chan struct{}),
+	}
+	c.forward(deliveries)
+	mc.consumers = append(mc.consumers, c)
+	return c.out, nil
+}
+
+func (mc *managedChannel) Cancel(consumer string, noWait bool) error {
+	mc.mu.Lock()
+	if mc.closed {
+		mc.mu.Unlock()
+		return amqp.ErrClosed
+	}
+	ch := mc.ch
+	var cancelled *managedConsumer
+	mc.consumers = slices.DeleteFunc(mc.consumers, func(c *managedConsumer) bool {
+		if c.tag == consumer {
+			cancelled = c
+			return true
+		}
+		return false
+	})
+	mc.mu.Unlock()
+
+	err := ch.Cancel(consumer, noWait)
+	if cancelled == nil {
+		return err
+	}
+	if err != nil && !errors.Is(err, amqp.ErrClosed) {
+		close(cancelled.done)
+	}
+	// The underlying delivery channel closes once the cancel goes through.
+	go func() {
+		cancelled.wg.Wait()
+		close(cancelled.out)
+	}()
+	return err
+}
+
+func (mc *managedChannel) Get(queue string, autoAck bool) (amqp.Delivery, bool, error) {
+	ch, err := mc.current()
+	if err != nil {
+		return amqp.Delivery{}, false, err
+	}
+	return ch.Get(queue, autoAck)
+}
+
+func (mc *managedChannel) QueuePurge(name string, noWait bool) (int, error) {
+	ch, err := mc.current()
+	if err != nil {
+		return 0, err
+	}
+	return ch.QueuePurge(name, noWait)
+}
+
+func (mc *managedChannel) PublishWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	ch, err := mc.current()
+	if err != nil {
+		return err
+	}
+	return ch.PublishWithContext(ctx, exchange, key, mandatory, immediate, msg)
+}
+
+func (mc *managedChannel) Confirm(noWait bool) error {
+	return mc.record(func(ch Channel) error {
+		return ch.Confirm(noWait)
+	})
+}
+
+// GetNextPublishSeqNo reports the sequence number of the current underlying
+// channel, which starts again at 1 after a reconnect.
+func (mc *managedChannel) GetNextPublishSeqNo() uint64 {
+	ch, err := mc.current()
+	if err != nil {
+		return 0
+	}
+	return ch.GetNextPublishSeqNo()
+}
+
+func (mc *managedChannel) NotifyPublish(receiver chan amqp.Confirmation) chan amqp.Confirmation {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if mc.closed {
+		close(receiver)
+		return receiver
+	}
+	mc.confirms = append(mc.confirms, receiver)
+	return receiver
+}
+
+func (mc *managedChannel) NotifyReturn(receiver chan amqp.Return) chan amqp.Return {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if mc.closed {
+		close(receiver)
+		return receiver
+	}
+	mc.returns = append(mc.returns, receiver)
+	return receiver
+}
+
+// bridge forwards confirmations and returns from an underlying channel to
+// the receivers registered on mc. A single goroutine handles both so that a
+// basic.return always reaches receivers before the confirmation of the same
+// message, as it does on a plain amqp.Channel. As there, receivers must keep
+// reading: until they do, the bridge, and with it the connection, waits.
+func (mc *managedChannel) bridge(ch Channel) {
+	confirms := ch.NotifyPublish(make(chan amqp.Confirmation, 1))
+	returns := ch.NotifyReturn(make(chan amqp.Return, 1))
+	mc.bridges.Add(1)
+	go func() {
+		defer mc.bridges.Done()
+		for confirms != nil || returns != nil {
+			select {
+			case r, ok := <-returns:
+				if !ok {
+					returns = nil
+					continue
+				}
+				mc.forwardReturn(r)
+			case c, ok := <-confirms:
+				if !ok {
+					confirms = nil
+					continue
+				}
+				select {
+				case r, ok := <-returns:
+					if ok {
+						mc.forwardReturn(r)
+					} else {
+						returns = nil
+					}
+				default:
+				}
+				mc.forwardConfirm(c)
+			}
+		}
+	}()
+}
+
+func (mc *managedChannel) forwardConfirm(c amqp.Confirmation) {
+	mc.mu.Lock()
+	receivers := slices.Clone(mc.confirms)
+	mc.mu.Unlock()
+	for _, r := range receivers {
+		r <- c
+	}
+}
+
+func (mc *managedChannel) forwardReturn(ret amqp.Return) {
+	mc.mu.Lock()
+	receivers := slices.Clone(mc.returns)
+	mc.mu.Unlock()
+	for _, r := range receivers {
+		r <- ret
+	}
+}
+
+func (mc *managedChannel) NotifyClose(receiver chan *amqp.Error) chan *amqp.Error {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if mc.closed {
+		close(receiver)
+		return receiver
+	}
+	mc.notify = append(mc.notify, receiver)
+	return receiver
+}
+
+func (mc *managedChannel) Close() error {
+	b := mc.broker
+	b.mu.Lock()
+	delete(b.channels, mc)
+	b.mu.Unlock()
+
+	mc.mu.Lock()
+	if mc.closed {
+		mc.mu.Unlock()
+		return amqp.ErrClosed
+	}
+	mc.closed = true
+	ch := mc.ch
+	consumers := mc.consumers
+	notify := mc.notify
+	confirms := mc.confirms
+	returns := mc.returns
+	mc.mu.Unlock()
+
+	for _, c := range consumers {
+		close(c.done)
+	}
+	err := ch.Close()
+	for _, c := range consumers {
+		c.wg.Wait()
+		close(c.out)
+	}
+	mc.bridges.Wait()
+	for _, r := range confirms {
+		close(r)
+	}
+	for _, r := range returns {
+		close(r)
+	}
+	for _, r := range notify {
+		close(r)
+	}
+	if errors.Is(err, amqp.ErrClosed) {
+		// The connection already went away underneath us.
+		return nil
+	}
+	return err
+}
+
+func (c *managedConsumer) forward(deliveries <-chan amqp.Delivery) {
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		for d := range deliveries {
+			select {
+			case c.out <- d:
+			case <-c.done:
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,161 @@
+package pubsub
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func newTestManagedBroker(t *testing.T, dial func() (Broker, error)) (*ManagedBroker, <-chan struct{}) {
+	t.Helper()
+	reconnected := make(chan struct{}, 1)
+	b, err := NewManagedBroker(dial, ReconnectOptions{
+		MinBackoff: time.Millisecond,
+		MaxBackoff: 10 * time.Millisecond,
+		OnReconnected: func() {
+			reconnected <- struct{}{}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { b.Close() })
+	return b, reconnected
+}
+
+func waitReconnected(t *testing.T, reconnected <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-reconnected:
+	case <-time.After(receiveTimeout):
+		t.Fatal("timed out waiting to reconnect")
+	}
+}
+
+func TestManagedBrokerRestoresConsumers(t *testing.T) {
+	s := NewMemoryServer()
+	b, reconnected := newTestManagedBroker(t, func() (Broker, error) {
+		return s.Connect(), nil
+	})
+
+	ch, err := b.Channel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Everything here is transient, so the restart deletes it and only the
+	// replayed declarations bring it back.
+	if err := ch.ExchangeDeclare("topic", amqp.ExchangeTopic, false, false, false, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ch.QueueDeclare("q", false, false, true, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := ch.QueueBind("q", "logs.*", "topic", false, nil); err != nil {
+		t.Fatal(err)
+	}
+	deliveries, err := ch.Consume("q", "consumer", true, false, false, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publish(t, newMemoryChannel(t, s), "topic", "logs.alice", "before")
+	if d := receive(t, deliveries); string(d.Body) != "before" {
+		t.Errorf("got %q, want before", d.Body)
+	}
+
+	s.Restart()
+	waitReconnected(t, reconnected)
+
+	publish(t, newMemoryChannel(t, s), "topic", "logs.alice", "after")
+	d := receive(t, deliveries)
+	if string(d.Body) != "after" {
+		t.Errorf("got %q, want after", d.Body)
+	}
+	if d.ConsumerTag != "consumer" {
+		t.Errorf("consumer tag = %q, want consumer", d.ConsumerTag)
+	}
+}
+
+func TestManagedBrokerRetriesDial(t *testing.T) {
+	s := NewMemoryServer()
+	var failures atomic.Int32
+	b, reconnected := newTestManagedBroker(t, func() (Broker, error) {
+		if failures.Load() > 0 {
+			failures.Add(-1)
+			return nil, errors.New("connection refused")
+		}
+		return s.Connect(), nil
+	})
+	ch, err := b.Channel()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failures.Store(3)
+	s.Restart()
+	waitReconnected(t, reconnected)
+	if n := failures.Load(); n != 0 {
+		t.Errorf("reconnected with %d failures left", n)
+	}
+	if _, err := ch.QueueDeclare("q", false, false, false, false, nil); err != nil {
+		t.Errorf("channel unusable after reconnecting: %v", err)
+	}
+}
+
+func TestManagedBrokerClose(t *testing.T) {
+	s := NewMemoryServer()
+	b, _ := newTestManagedBroker(t, func() (Broker, error) {
+		return s.Connect(), nil
+	})
+	ch, err := b.Channel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ch.QueueDeclare("q", false, false, false, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	deliveries, err := ch.Consume("q", "", true, false, false, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case _, ok := <-deliveries:
+		if ok {
+			t.Error("got a delivery after closing")
+		}
+	case <-time.After(receiveTimeout):
+		t.Error("deliveries were not closed with the broker")
+	}
+	if _, err := b.Channel(); !errors.Is(err, amqp.ErrClosed) {
+		t.Errorf("Channel after Close = %v, want ErrClosed", err)
+	}
+}
+
+func TestManagedChannelDoesNotRecordPassiveDeclares(t *testing.T) {
+	s := NewMemoryServer()
+	b, _ := newTestManagedBroker(t, func() (Broker, error) {
+		return s.Connect(), nil
+	})
+	ch, err := b.Channel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ch.QueueDeclare("q", true, false, false, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	for range 100 {
+		if _, err := ch.QueueDeclarePassive("q", true, false, false, false, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(ch.(*managedChannel).topology); n != 1 {
+		t.Errorf("recorded %d operations, want only the declare", n)
+	}
+}
@@ -0,0 +1,97 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const HeaderAttempt = "x-peril-attempt"
+
+// RetryPolicy controls what NackRetryLater does. Attempt n is retried after
+// Delays[n-1], or the last delay once they run out. A message that has been
+// handled MaxAttempts times is rejected to the dead-letter exchange instead.
+type RetryPolicy struct {
+	Delays      []time.Duration
+	MaxAttempts int
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	Delays:      []time.Duration{time.Second, 5 * time.Second, 30 * time.Second},
+	MaxAttempts: 5,
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if len(p.Delays) == 0 {
+		p.Delays = DefaultRetryPolicy.Delays
+	}
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = DefaultRetryPolicy.MaxAttempts
+	}
+	return p
+}
+
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	return p.Delays[min(attempt, len(p.Delays))-1]
+}
+
+// RetryQueueName is the queue that holds messages from queue while they wait
+// delay to be retried, e.g. game_logs.retry.5s.
+func RetryQueueName(queue string, delay time.Duration) string {
+	return fmt.Sprintf("%s.retry.%s", queue, delay)
+}
+
+// retryQueueSpecs describes one queue per delay. They have no consumers:
+// messages expire after the delay and are dead-lettered through the default
+// exchange back onto queue.
+func retryQueueSpecs(queue string, durable bool, p RetryPolicy) []QueueSpec {
+	specs := make([]QueueSpec, 0, len(p.Delays))
+	for _, delay := range p.Delays {
+		specs = append(specs, QueueSpec{
+			Name:    RetryQueueName(queue, delay),
+			Durable: durable,
+			Args: amqp.Table{
+				"x-message-ttl":             delay.Milliseconds(),
+				"x-dead-letter-exchange":    "",
+				"x-dead-letter-routing-key": queue,
+			},
+		})
+	}
+	return specs
+}
+
+// attemptOf is how many times d has been delivered to a handler, counting
+// this delivery.
+func attemptOf(d amqp.Delivery) int {
+	if n, ok := tableInt(d.Headers[HeaderAttempt]); ok {
+		return int(n)
+	}
+	return 1
+}
+
+// retryLater parks d in the retry queue for its attempt and acks it, or
+// rejects it once the policy's attempts are used up. If the retry queue
+// cannot be published to, d is requeued as before.
+func retryLater(ch Publisher, queue string, p RetryPolicy, d amqp.Delivery) error {
+	attempt := attemptOf(d)
+	if attempt >= p.MaxAttempts {
+		return d.Nack(false, false)
+	}
+
+	pub := deliveryToPublishing(d)
+	pub.Headers[HeaderAttempt] = int32(attempt + 1)
+	// Retries come back through the default exchange, so remember where the
+	// message was first published for the DLQ to replay it there.
+	if _, ok := pub.Headers[HeaderOriginalExchange]; !ok {
+		pub.Headers[HeaderOriginalExchange] = d.Exchange
+		pub.Headers[HeaderOriginalRoutingKey] = d.RoutingKey
+	}
+	err := ch.PublishWithContext(context.Background(), "", RetryQueueName(queue, p.delay(attempt)), false, false, pub)
+	if err != nil {
+		d.Nack(false, true)
+		return err
+	}
+	return d.Ack(false)
+}
@@ -0,0 +1,121 @@
+package pubsub
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestRetryQueueName(t *testing.T) {
+	got := RetryQueueName("game_logs", 5*time.Second)
+	if got != "game_logs.retry.5s" {
+		t.Errorf("RetryQueueName = %q, want game_logs.retry.5s", got)
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := RetryPolicy{Delays: []time.Duration{time.Second, 5 * time.Second}}.withDefaults()
+	if p.MaxAttempts != DefaultRetryPolicy.MaxAttempts {
+		t.Errorf("MaxAttempts = %d, want the default %d", p.MaxAttempts, DefaultRetryPolicy.MaxAttempts)
+	}
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 5 * time.Second, 3: 5 * time.Second} {
+		if got := p.delay(attempt); got != want {
+			t.Errorf("delay(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+}
+
+func TestSubscribeRetryLater(t *testing.T) {
+	s := NewMemoryServer()
+	ch := newMemoryChannel(t, s)
+	if err := ch.ExchangeDeclare("direct", amqp.ExchangeDirect, true, false, false, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := ch.ExchangeDeclare("dlx", amqp.ExchangeFanout, true, false, false, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ch.QueueDeclare("dlq", true, false, false, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := ch.QueueBind("dlq", "", "dlx", false, nil); err != nil {
+		t.Fatal(err)
+	}
+	dead, err := ch.Consume("dlq", "", true, false, false, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	policy := RetryPolicy{Delays: []time.Duration{10 * time.Millisecond}, MaxAttempts: 3}
+	var attempts atomic.Int32
+	sub, err := SubscribeJSON(context.Background(), s.Connect(), "direct", "work", "work", Durable,
+		func(ctx context.Context, body string) Acktype {
+			attempts.Add(1)
+			return NackRetryLater
+		},
+		WithRetry(policy),
+		WithDeadLetter("dlx"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	if _, _, err := ch.Get(RetryQueueName("work", 10*time.Millisecond), true); err != nil {
+		t.Errorf("retry queue was not declared: %v", err)
+	}
+
+	if err := PublishJSON(context.Background(), ch, "direct", "work", "job"); err != nil {
+		t.Fatal(err)
+	}
+	d := receive(t, dead)
+	if got := attempts.Load(); got != int32(policy.MaxAttempts) {
+		t.Errorf("handled %d times, want %d", got, policy.MaxAttempts)
+	}
+	if n := attemptOf(d); n != policy.MaxAttempts {
+		t.Errorf("dead-lettered on attempt %d, want %d", n, policy.MaxAttempts)
+	}
+	if d.Headers[HeaderOriginalExchange] != "direct" || d.Headers[HeaderOriginalRoutingKey] != "work" {
+		t.Errorf("original exchange and key = %v %v, want direct work",
+			d.Headers[HeaderOriginalExchange], d.Headers[HeaderOriginalRoutingKey])
+	}
+}
+
+func TestSubscribeRetryLaterWithoutPolicy(t *testing.T) {
+	s := NewMemoryServer()
+	ch := newMemoryChannel(t, s)
+	if err := ch.ExchangeDeclare("direct", amqp.ExchangeDirect, true, false, false, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ch.QueueDeclare("dlq", true, false, false, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	dead, err := ch.Consume("dlq", "", true, false, false, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var attempts atomic.Int32
+	sub, err := SubscribeJSON(context.Background(), s.Connect(), "direct", "work", "work", Durable,
+		func(ctx context.Context, body string) Acktype {
+			attempts.Add(1)
+			return NackRetryLater
+		},
+		WithDeadLetter(""),
+		WithQueueArgs(amqp.Table{"x-dead-letter-exchange": "", "x-dead-letter-routing-key": "dlq"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	if err := PublishJSON(context.Background(), ch, "direct", "work", "job"); err != nil {
+		t.Fatal(err)
+	}
+	receive(t, dead)
+	if got := attempts.Load(); got != 1 {
+		t.Errorf("handled %d times, want 1", got)
+	}
+}
@@ -0,0 +1,242 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const HeaderRPCError = "x-peril-rpc-error"
+
+// RemoteError is returned by Call when the responder's handler failed.
+type RemoteError struct {
+	Message string
+}
+
+func (e *RemoteError) Error() string {
+	return "remote handler failed: " + e.Message
+}
+
+// RPCClient sends requests and matches replies to them by correlation ID.
+// Replies arrive on a private, exclusive queue that lives as long as the
+// client's connection.
+type RPCClient struct {
+	ch         Channel
+	codec      Codec
+	timeout    time.Duration
+	replyQueue string
+
+	mu      sync.Mutex
+	pending map[string]chan rpcResult
+}
+
+type rpcResult struct {
+	d   amqp.Delivery
+	err error
+}
+
+// NewRPCClient opens a channel on conn for requests encoded with codec.
+// timeout bounds calls whose context has no deadline; zero means wait for as
+// long as the context allows.
+func NewRPCClient(conn Broker, codec Codec, timeout time.Duration) (*RPCClient, error) {
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+	// The name is chosen here rather than by the broker, so a ManagedBroker
+	// redeclares the same queue after a reconnect.
+	replyQueue := fmt.Sprintf("peril.rpc.%s", newMessageID())
+	_, err = ch.QueueDeclare(replyQueue, false, true, true, false, nil)
+	if err != nil {
+		ch.Close()
+		return nil, err
+	}
+	deliveries, err := ch.Consume(replyQueue, "", true, true, false, false, nil)
+	if err != nil {
+		ch.Close()
+		return nil, err
+	}
+
+	c := &RPCClient{
+		ch:         ch,
+		codec:      codec,
+		timeout:    timeout,
+		replyQueue: replyQueue,
+		pending:    map[string]chan rpcResult{},
+	}
+	go c.receive(deliveries, ch.NotifyReturn(make(chan amqp.Return, 1)))
+	return c, nil
+}
+
+func (c *RPCClient) receive(deliveries <-chan amqp.Delivery, returns <-chan amqp.Return) {
+	for deliveries != nil || returns != nil {
+		select {
+		case d, ok := <-deliveries:
+			if !ok {
+				deliveries = nil
+				continue
+			}
+			c.resolve(d.CorrelationId, rpcResult{d: d})
+		case r, ok := <-returns:
+			if !ok {
+				returns = nil
+				continue
+			}
+			c.resolve(r.CorrelationId, rpcResult{err: &ReturnError{
+				Exchange:   r.Exchange,
+				RoutingKey: r.RoutingKey,
+				ReplyCode:  r.ReplyCode,
+				ReplyText:  r.ReplyText,
+			}})
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, reply := range c.pending {
+		reply <- rpcResult{err: amqp.ErrClosed}
+		delete(c.pending, id)
+	}
+}
+
+// resolve hands a reply to the call waiting for it. Replies to calls that
+// already gave up are dropped.
+func (c *RPCClient) resolve(id string, res rpcResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	reply, ok := c.pending[id]
+	if !ok {
+		return
+	}
+	delete(c.pending, id)
+	reply <- res
+}
+
+func (c *RPCClient) Close() error {
+	return c.ch.Close()
+}
+
+// Call publishes req to exchange with key and waits for the reply. A request
+// that no queue accepts fails straight away with ErrUnroutable, and one the
+// responder fails to handle with a *RemoteError. The request expires on the
+// broker when the call gives up, so a late responder does not act on it.
+func Call[Req, Resp any](ctx context.Context, c *RPCClient, exchange, key string, req Req) (Resp, error) {
+	var zero Resp
+	if _, ok := ctx.Deadline(); !ok && c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	body, err := c.codec.Marshal(req)
+	if err != nil {
+		return zero, err
+	}
+
+	id := newMessageID()
+	reply := make(chan rpcResult, 1)
+	c.mu.Lock()
+	c.pending[id] = reply
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	pub := amqp.Publishing{
+		ContentType: c.codec.ContentType(),
+		ReplyTo:     c.replyQueue,
+		Body:        body,
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		pub.Expiration = strconv.FormatInt(max(time.Until(deadline).Milliseconds(), 1), 10)
+	}
+	// The request's correlation ID is what the reply is matched by.
+	newEnvelope(WithCorrelationID(ctx, id), req).apply(&pub)
+	err = c.ch.PublishWithContext(ctx, exchange, key, true, false, pub)
+	if err != nil {
+		return zero, err
+	}
+
+	select {
+	case res := <-reply:
+		if res.err != nil {
+			return zero, res.err
+		}
+		if msg, ok := res.d.Headers[HeaderRPCError].(string); ok {
+			return zero, &RemoteError{Message: msg}
+		}
+		return decode[Resp](res.d, c.codec)
+	case <-ctx.Done():
+		return zero, fmt.Errorf("waiting for reply to %s: %w", key, ctx.Err())
+	}
+}
+
+// Serve subscribes handler to requests on queueName, like Subscribe, and
+// sends each response, or the handler's error, back to the requester in the
+// request's content type. Requests without a reply-to address are handled
+// and acked with no reply.
+func Serve[Req, Resp any](
+	ctx context.Context,
+	conn Broker,
+	exchange,
+	queueName,
+	key string,
+	queueType SimpleQueueType,
+	handler func(context.Context, Req) (Resp, error),
+	opts ...SubscribeOption,
+) (*Subscription, error) {
+	replies, err := conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+
+	sub, err := Subscribe(ctx, conn, exchange, queueName, key, queueType, func(ctx context.Context, req Req) Acktype {
+		resp, err := handler(ctx, req)
+		env, _ := EnvelopeFromContext(ctx)
+		if env.ReplyTo == "" {
+			return Ack
+		}
+		err = sendReply(ctx, replies, env, resp, err)
+		if err != nil {
+			// The requester times out; a retry would most likely be too late.
+			return NackDiscard
+		}
+		return Ack
+	}, opts...)
+	if err != nil {
+		replies.Close()
+		return nil, err
+	}
+	go func() {
+		<-sub.Done()
+		replies.Close()
+	}()
+	return sub, nil
+}
+
+func sendReply[Resp any](ctx context.Context, ch Publisher, request Envelope, resp Resp, handlerErr error) error {
+	codec, err := CodecFor(request.ContentType)
+	if err != nil {
+		codec = JSON
+	}
+
+	pub := amqp.Publishing{
+		ContentType: codec.ContentType(),
+	}
+	if handlerErr != nil {
+		pub.Headers = amqp.Table{HeaderRPCError: handlerErr.Error()}
+	} else {
+		pub.Body, err = codec.Marshal(resp)
+		if err != nil {
+			pub.Headers = amqp.Table{HeaderRPCError: err.Error()}
+		}
+	}
+	newEnvelope(ctx, resp).apply(&pub)
+	return ch.PublishWithContext(ctx, "", request.ReplyTo, false, false, pub)
+}
@@ -0,0 +1,111 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// serveDouble answers requests on the "rpc" exchange's "double" key with
+// twice the number sent, failing for negative numbers.
+func serveDouble(t *testing.T, s *MemoryServer) {
+	t.Helper()
+	ch := newMemoryChannel(t, s)
+	if err := ch.ExchangeDeclare("rpc", amqp.ExchangeDirect, true, false, false, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	sub, err := Serve(context.Background(), s.Connect(), "rpc", "double", "double", Transient,
+		func(ctx context.Context, n int) (int, error) {
+			if n < 0 {
+				return 0, errors.New("negative")
+			}
+			return 2 * n, nil
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sub.Close() })
+}
+
+func newTestRPCClient(t *testing.T, s *MemoryServer, timeout time.Duration) *RPCClient {
+	t.Helper()
+	c, err := NewRPCClient(s.Connect(), JSON, timeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestCall(t *testing.T) {
+	s := NewMemoryServer()
+	serveDouble(t, s)
+	c := newTestRPCClient(t, s, receiveTimeout)
+
+	for _, n := range []int{1, 21} {
+		got, err := Call[int, int](context.Background(), c, "rpc", "double", n)
+		if err != nil {
+			t.Fatalf("Call(%d): %v", n, err)
+		}
+		if got != 2*n {
+			t.Errorf("Call(%d) = %d, want %d", n, got, 2*n)
+		}
+	}
+}
+
+func TestCallRemoteError(t *testing.T) {
+	s := NewMemoryServer()
+	serveDouble(t, s)
+	c := newTestRPCClient(t, s, receiveTimeout)
+
+	_, err := Call[int, int](context.Background(), c, "rpc", "double", -1)
+	var remote *RemoteError
+	if !errors.As(err, &remote) {
+		t.Fatalf("got %v, want a RemoteError", err)
+	}
+	if remote.Message != "negative" {
+		t.Errorf("remote error = %q, want negative", remote.Message)
+	}
+}
+
+func TestCallUnroutable(t *testing.T) {
+	s := NewMemoryServer()
+	serveDouble(t, s)
+	c := newTestRPCClient(t, s, receiveTimeout)
+
+	_, err := Call[int, int](context.Background(), c, "rpc", "triple", 1)
+	if !errors.Is(err, ErrUnroutable) {
+		t.Errorf("got %v, want ErrUnroutable", err)
+	}
+}
+
+func TestCallTimeout(t *testing.T) {
+	s := NewMemoryServer()
+	ch := newMemoryChannel(t, s)
+	if err := ch.ExchangeDeclare("rpc", amqp.ExchangeDirect, true, false, false, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	// A queue nobody consumes from: the request is routed but never answered.
+	if _, err := ch.QueueDeclare("double", true, false, false, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := ch.QueueBind("double", "double", "rpc", false, nil); err != nil {
+		t.Fatal(err)
+	}
+	c := newTestRPCClient(t, s, 20*time.Millisecond)
+
+	_, err := Call[int, int](context.Background(), c, "rpc", "double", 1)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want DeadlineExceeded", err)
+	}
+	// The request expires when the call gives up, so no late responder
+	// acts on it.
+	time.Sleep(20 * time.Millisecond)
+	if body, ok := getBody(t, ch, "double"); ok {
+		t.Errorf("request %q outlived the call", body)
+	}
+}
@@ -0,0 +1,163 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Subscription is a running consumer started by SubscribeJSON or
+// SubscribeGOB. Cancelling the context it was started with begins a drain.
+type Subscription struct {
+	ch  Channel
+	tag string
+
+	cancelOnce sync.Once
+	stopOnce   sync.Once
+	stop       chan struct{}
+	done       chan struct{}
+	closeErr   error
+}
+
+var consumerSeq atomic.Uint64
+
+func newConsumerTag() string {
+	return fmt.Sprintf("peril-%d-%d", os.Getpid(), consumerSeq.Add(1))
+}
+
+func newSubscription(ch Channel, tag string) *Subscription {
+	return &Subscription{
+		ch:   ch,
+		tag:  tag,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+}
+
+// Done is closed once the subscription has stopped consuming, every handler
+// has returned and its channel has been closed.
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Drain stops the broker from sending new messages, finishes handling and
+// acking those already delivered, then closes the channel. If ctx ends first
+// the remaining deliveries are left unacked, to be requeued by the broker.
+func (s *Subscription) Drain(ctx context.Context) error {
+	s.cancel()
+	select {
+	case <-s.done:
+		return s.closeErr
+	case <-ctx.Done():
+		s.halt()
+		<-s.done
+		return ctx.Err()
+	}
+}
+
+// Close stops consuming as soon as the handler currently running, if any,
+// has returned and acked. Messages delivered but not yet handled are
+// requeued.
+func (s *Subscription) Close() error {
+	s.cancel()
+	s.halt()
+	<-s.done
+	return s.closeErr
+}
+
+func (s *Subscription) cancel() {
+	s.cancelOnce.Do(func() {
+		if err := s.ch.Cancel(s.tag, false); err != nil {
+			// Without a cancelled consumer the delivery channel may never
+			// close, so stop reading from it instead.
+			s.halt()
+		}
+	})
+}
+
+func (s *Subscription) halt() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+}
+
+// run feeds deliveries to handle until the delivery channel closes or the
+// subscription is halted, then waits for running handlers before finishing.
+func (s *Subscription) run(deliveries <-chan amqp.Delivery, cfg subscribeConfig, handle func(amqp.Delivery)) {
+	defer s.finish()
+
+	workers := make([]chan amqp.Delivery, cfg.concurrency)
+	var wg sync.WaitGroup
+	for i := range workers {
+		// Without an ordering key all workers share one queue of work.
+		if i == 0 || cfg.orderingKey != nil {
+			workers[i] = make(chan amqp.Delivery)
+		} else {
+			workers[i] = workers[0]
+		}
+		wg.Add(1)
+		go func(work <-chan amqp.Delivery) {
+			defer wg.Done()
+			for d := range work {
+				handle(d)
+			}
+		}(workers[i])
+	}
+	defer func() {
+		close(workers[0])
+		if cfg.orderingKey != nil {
+			for _, w := range workers[1:] {
+				close(w)
+			}
+		}
+		wg.Wait()
+	}()
+
+	for {
+		var d amqp.Delivery
+		var ok bool
+		select {
+		case <-s.stop:
+			return
+		case d, ok = <-deliveries:
+			if !ok {
+				return
+			}
+		}
+
+		work := workers[0]
+		if cfg.orderingKey != nil {
+			h := fnv.New32a()
+			h.Write([]byte(cfg.orderingKey(d)))
+			work = workers[h.Sum32()%uint32(len(workers))]
+		}
+		select {
+		case <-s.stop:
+			return
+		case work <- d:
+		}
+	}
+}
+
+func (s *Subscription) drainOnDone(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+		s.cancel()
+	case <-s.done:
+	}
+}
+
+// finish runs when the consume loop exits.
+func (s *Subscription) finish() {
+	err := s.ch.Close()
+	if err != nil && !errors.Is(err, amqp.ErrClosed) {
+		s.closeErr = err
+	}
+	close(s.done)
+}
@@ -0,0 +1,214 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// gatedSubscription subscribes to queue work with a handler that reports
+// each message's N on started, then waits for release to close.
+type gatedSubscription struct {
+	*Subscription
+	ch      Channel
+	started chan int
+	release chan struct{}
+}
+
+func newGatedSubscription(t *testing.T, ctx context.Context, messages int) gatedSubscription {
+	t.Helper()
+	s := NewMemoryServer()
+	g := gatedSubscription{
+		ch:      newMemoryChannel(t, s),
+		started: make(chan int, messages),
+		release: make(chan struct{}),
+	}
+	newWorkExchange(t, g.ch)
+	sub, err := SubscribeJSON(ctx, s.Connect(), "direct", "work", "work", Durable,
+		func(_ context.Context, m testMessage) Acktype {
+			g.started <- m.N
+			<-g.release
+			return Ack
+		}, WithDeadLetter("dlx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Subscription = sub
+	for n := range messages {
+		if err := PublishJSON(context.Background(), g.ch, "direct", "work", testMessage{N: n}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := receiveValue(t, g.started); n != 0 {
+		t.Fatalf("handling %d first, want 0", n)
+	}
+	return g
+}
+
+// checkStillRunning fails if the subscription finishes while a handler is
+// blocked.
+func (g gatedSubscription) checkStillRunning(t *testing.T) {
+	t.Helper()
+	select {
+	case <-g.Done():
+		t.Fatal("the subscription finished while a handler was running")
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+// checkNothingLost fails unless every message was either handled and acked,
+// or is back in the queue for the next consumer.
+func (g gatedSubscription) checkNothingLost(t *testing.T, messages int) int {
+	t.Helper()
+	handled := 1 + len(g.started)
+	left, err := g.ch.QueuePurge("work", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if handled+left != messages {
+		t.Errorf("%d messages handled and %d left in the queue, want %d in all", handled, left, messages)
+	}
+	return handled
+}
+
+func TestSubscriptionDrain(t *testing.T) {
+	g := newGatedSubscription(t, context.Background(), 3)
+	drained := make(chan error, 1)
+	go func() { drained <- g.Drain(context.Background()) }()
+	g.checkStillRunning(t)
+
+	close(g.release)
+	if err := receiveValue(t, drained); err != nil {
+		t.Fatalf("drain: %v", err)
+	}
+	select {
+	case <-g.Done():
+	default:
+		t.Error("Done is open after the drain")
+	}
+	g.checkNothingLost(t, 3)
+}
+
+func TestSubscriptionDrainTimeout(t *testing.T) {
+	g := newGatedSubscription(t, context.Background(), 3)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	drained := make(chan error, 1)
+	go func() { drained <- g.Drain(ctx) }()
+
+	// Drain still waits for the running handler, which must not be
+	// interrupted mid-message.
+	<-ctx.Done()
+	g.checkStillRunning(t)
+	close(g.release)
+	if err := receiveValue(t, drained); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("drain: got %v, want DeadlineExceeded", err)
+	}
+	g.checkNothingLost(t, 3)
+}
+
+func TestSubscriptionClose(t *testing.T) {
+	g := newGatedSubscription(t, context.Background(), 3)
+	closed := make(chan error, 1)
+	go func() { closed <- g.Close() }()
+	g.checkStillRunning(t)
+
+	close(g.release)
+	if err := receiveValue(t, closed); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	// Close does not wait for messages that were delivered but not yet
+	// handled, so one of them may have been handled as well.
+	if handled := g.checkNothingLost(t, 3); handled > 2 {
+		t.Errorf("handled %d messages after Close, want at most 2", handled)
+	}
+	if err := g.Close(); err != nil {
+		t.Errorf("closing twice: %v", err)
+	}
+}
+
+func TestSubscriptionDrainsWhenContextEnds(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	g := newGatedSubscription(t, ctx, 2)
+	cancel()
+	g.checkStillRunning(t)
+
+	close(g.release)
+	select {
+	case <-g.Done():
+	case <-time.After(receiveTimeout):
+		t.Fatal("the subscription did not finish after its context ended")
+	}
+	g.checkNothingLost(t, 2)
+}
+
+func TestByRoutingKey(t *testing.T) {
+	if key := ByRoutingKey(amqp.Delivery{RoutingKey: "game_logs.alice"}); key != "game_logs.alice" {
+		t.Errorf("got %q, want game_logs.alice", key)
+	}
+	retried := amqp.Delivery{
+		RoutingKey: "game_logs",
+		Headers:    amqp.Table{HeaderOriginalRoutingKey: "game_logs.alice"},
+	}
+	if key := ByRoutingKey(retried); key != "game_logs.alice" {
+		t.Errorf("retried message: got %q, want game_logs.alice", key)
+	}
+}
+
+func TestSubscribeOrderingKey(t *testing.T) {
+	const perKey = 20
+	keys := []string{"a", "b", "c", "d"}
+	s := NewMemoryServer()
+	ch := newMemoryChannel(t, s)
+	newWorkExchange(t, ch)
+
+	var mu sync.Mutex
+	seen := map[string][]int{}
+	done := make(chan struct{})
+	sub, err := SubscribeJSON(context.Background(), s.Connect(), "direct", "work", "work", Durable,
+		func(ctx context.Context, m testMessage) Acktype {
+			env, _ := EnvelopeFromContext(ctx)
+			// Give other workers a chance to overtake.
+			time.Sleep(time.Duration(rand.IntN(500)) * time.Microsecond)
+			mu.Lock()
+			defer mu.Unlock()
+			seen[env.RoutingKey] = append(seen[env.RoutingKey], m.N)
+			if len(seen[env.RoutingKey]) == perKey {
+				done <- struct{}{}
+			}
+			return Ack
+		},
+		WithConcurrency(4),
+		WithOrderingKey(ByRoutingKey),
+		WithMultipleBindings(keys...),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	for n := range perKey {
+		for _, key := range keys {
+			if err := PublishJSON(context.Background(), ch, "direct", key, testMessage{N: n}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for range keys {
+		receiveValue(t, done)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, key := range keys {
+		if !slices.IsSorted(seen[key]) {
+			t.Errorf("key %s was handled out of order: %v", key, seen[key])
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package routing
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrInvalidUsername = errors.New("invalid username")
+	ErrInvalidKey      = errors.New("invalid routing key")
+)
+
+// KeyKind is the first word of a per-player routing key.
+type KeyKind string
+
+const (
+	KindArmyMove KeyKind = ArmyMovesPrefix
+	KindWar      KeyKind = WarRecognitionsPrefix
+	KindGameLog  KeyKind = GameLogSlug
+	KindPause    KeyKind = PauseKey
+	KindRound    KeyKind = RoundKey
+	KindGameOver KeyKind = GameOverKey
+
+	KindOrder        KeyKind = OrdersPrefix
+	KindPlayerUpdate KeyKind = PlayerUpdatesPrefix
+)
+
+// For returns the key for username, e.g. army_moves.alice.
+func (k KeyKind) For(username string) string {
+	return string(k) + "." + username
+}
+
+// Wildcard is the binding key that matches every player's keys of this kind,
+// e.g. army_moves.*.
+func (k KeyKind) Wildcard() string {
+	return string(k) + ".*"
+}
+
+func ArmyMoveKey(username string) string { return KindArmyMove.For(username) }
+
+func WarKey(username string) string { return KindWar.For(username) }
+
+func GameLogKey(username string) string { return KindGameLog.For(username) }
+
+func OrderKey(username string) string { return KindOrder.For(username) }
+
+func PlayerUpdateKey(username string) string { return KindPlayerUpdate.For(username) }
+
+// UserPauseKey names a player's own pause queue. Pause messages themselves
+// are published with PauseKey.
+func UserPauseKey(username string) string { return KindPause.For(username) }
+
+// UserRoundKey names a player's own round queue. Rounds themselves are
+// published with RoundKey.
+func UserRoundKey(username string) string { return KindRound.For(username) }
+
+// UserGameOverKey names a player's own game over queue. The end of the game
+// is published with GameOverKey.
+func UserGameOverKey(username string) string { return KindGameOver.For(username) }
+
+// ValidateUsername rejects names that would break routing keys: dots split
+// keys into words, and '*' and '#' are topic wildcards.
+func ValidateUsername(username string) error {
+	if username == "" {
+		return fmt.Errorf("%w: it cannot be empty", ErrInvalidUsername)
+	}
+	if strings.ContainsAny(username, ".*#") {
+		return fmt.Errorf("%w: %q cannot contain '.', '*' or '#'", ErrInvalidUsername, username)
+	}
+	return nil
+}
+
+// ParseKey splits a per-player routing key such as war.alice into its kind
+// and username.
+func ParseKey(key string) (KeyKind, string, error) {
+	prefix, username, ok := strings.Cut(key, ".")
+	if !ok {
+		return "", "", fmt.Errorf("%w: %q has no username", ErrInvalidKey, key)
+	}
+	kind := KeyKind(prefix)
+	switch kind {
+	case KindArmyMove, KindWar, KindGameLog, KindPause, KindRound, KindGameOver, KindOrder, KindPlayerUpdate:
+	default:
+		return "", "", fmt.Errorf("%w: unknown kind %q in %q", ErrInvalidKey, prefix, key)
+	}
+	err := ValidateUsername(username)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %q: %w", ErrInvalidKey, key, err)
+	}
+	return kind, username, nil
+}
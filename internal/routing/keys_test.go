@@ -0,0 +1,79 @@
+package routing
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		key      string
+		kind     KeyKind
+		username string
+		wantErr  error
+	}{
+		{"army_moves.alice", KindArmyMove, "alice", nil},
+		{"war.bob", KindWar, "bob", nil},
+		{"game_logs.carol", KindGameLog, "carol", nil},
+		{"pause.alice", KindPause, "alice", nil},
+		{"round.alice", KindRound, "alice", nil},
+		{"game_over.alice", KindGameOver, "alice", nil},
+		{"orders.alice", KindOrder, "alice", nil},
+		{"player_updates.alice", KindPlayerUpdate, "alice", nil},
+		{"war", "", "", ErrInvalidKey},
+		{"war.", "", "", ErrInvalidKey},
+		{"", "", "", ErrInvalidKey},
+		{"peace.alice", "", "", ErrInvalidKey},
+		{"war.alice.extra", "", "", ErrInvalidUsername},
+		{"war.*", "", "", ErrInvalidUsername},
+		{"war.#", "", "", ErrInvalidUsername},
+	}
+	for _, tt := range tests {
+		kind, username, err := ParseKey(tt.key)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("ParseKey(%q): got %v, want %v", tt.key, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			if !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("ParseKey(%q): %v is not ErrInvalidKey", tt.key, err)
+			}
+			continue
+		}
+		if kind != tt.kind || username != tt.username {
+			t.Errorf("ParseKey(%q) = %q, %q, want %q, %q", tt.key, kind, username, tt.kind, tt.username)
+		}
+		if key := kind.For(username); key != tt.key {
+			t.Errorf("%q.For(%q) = %q, want %q", kind, username, key, tt.key)
+		}
+	}
+}
+
+func TestValidateUsername(t *testing.T) {
+	tests := []struct {
+		username string
+		valid    bool
+	}{
+		{"alice", true},
+		{"Bob_2-x", true},
+		{"", false},
+		{"al.ice", false},
+		{"alice*", false},
+		{"#alice", false},
+	}
+	for _, tt := range tests {
+		err := ValidateUsername(tt.username)
+		if tt.valid && err != nil {
+			t.Errorf("ValidateUsername(%q): %v", tt.username, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidUsername) {
+			t.Errorf("ValidateUsername(%q): got %v, want ErrInvalidUsername", tt.username, err)
+		}
+	}
+}
+
+func TestWildcard(t *testing.T) {
+	if got := KindArmyMove.Wildcard(); got != "army_moves.*" {
+		t.Errorf("Wildcard() = %q, want army_moves.*", got)
+	}
+}
@@ -11,3 +11,7 @@ type GameLog struct {
 	Message     string
 	Username    string
 }
+
+type GameStateQuery struct {
+	Username string
+}
@@ -0,0 +1,68 @@
+package routing
+
+import (
+	"fmt"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing/routingpb"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+//go:generate protoc -I . --go_out=routingpb --go_opt=paths=source_relative routing.proto
+
+func (ps PlayingState) ToProto() proto.Message {
+	return &routingpb.PlayingState{IsPaused: ps.IsPaused}
+}
+
+func (ps *PlayingState) NewProto() proto.Message {
+	return &routingpb.PlayingState{}
+}
+
+func (ps *PlayingState) FromProto(m proto.Message) error {
+	pb, ok := m.(*routingpb.PlayingState)
+	if !ok {
+		return fmt.Errorf("expected %T, got %T", pb, m)
+	}
+	ps.IsPaused = pb.GetIsPaused()
+	return nil
+}
+
+func (gl GameLog) ToProto() proto.Message {
+	return &routingpb.GameLog{
+		CurrentTime: timestamppb.New(gl.CurrentTime),
+		Message:     gl.Message,
+		Username:    gl.Username,
+	}
+}
+
+func (gl *GameLog) NewProto() proto.Message {
+	return &routingpb.GameLog{}
+}
+
+func (gl *GameLog) FromProto(m proto.Message) error {
+	pb, ok := m.(*routingpb.GameLog)
+	if !ok {
+		return fmt.Errorf("expected %T, got %T", pb, m)
+	}
+	gl.CurrentTime = pb.GetCurrentTime().AsTime()
+	gl.Message = pb.GetMessage()
+	gl.Username = pb.GetUsername()
+	return nil
+}
+
+func (q GameStateQuery) ToProto() proto.Message {
+	return &routingpb.GameStateQuery{Username: q.Username}
+}
+
+func (q *GameStateQuery) NewProto() proto.Message {
+	return &routingpb.GameStateQuery{}
+}
+
+func (q *GameStateQuery) FromProto(m proto.Message) error {
+	pb, ok := m.(*routingpb.GameStateQuery)
+	if !ok {
+		return fmt.Errorf("expected %T, got %T", pb, m)
+	}
+	q.Username = pb.GetUsername()
+	return nil
+}
@@ -7,10 +7,29 @@ const (
 
 	PauseKey = "pause"
 
+	RoundKey = "round"
+
+	GameOverKey = "game_over"
+
 	GameLogSlug = "game_logs"
+
+	OrdersPrefix = "orders"
+
+	PlayerUpdatesPrefix = "player_updates"
+
+	GameStateQueryKey = "game_state"
+
+	PlayerStateQueryKey = "player_state"
+
+	MapQueryKey = "map"
 )
 
 const (
 	ExchangePerilDirect = "peril_direct"
 	ExchangePerilTopic  = "peril_topic"
+	ExchangePerilDLX    = "peril_dlx"
+)
+
+const (
+	QueuePerilDLQ = "peril_dlq"
 )
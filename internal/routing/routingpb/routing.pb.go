@@ -0,0 +1,241 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.9
+// 	protoc        (unknown)
+// source: routing.proto
+
+package routingpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Published to peril_direct with routing key pause.
+type PlayingState struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	IsPaused      bool                   `protobuf:"varint,1,opt,name=is_paused,json=isPaused,proto3" json:"is_paused,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PlayingState) Reset() {
+	*x = PlayingState{}
+	mi := &file_routing_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PlayingState) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PlayingState) ProtoMessage() {}
+
+func (x *PlayingState) ProtoReflect() protoreflect.Message {
+	mi := &file_routing_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PlayingState.ProtoReflect.Descriptor instead.
+func (*PlayingState) Descriptor() ([]byte, []int) {
+	return file_routing_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *PlayingState) GetIsPaused() bool {
+	if x != nil {
+		return x.IsPaused
+	}
+	return false
+}
+
+// Published to peril_topic with routing key game_logs.<username>.
+type GameLog struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	CurrentTime   *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=current_time,json=currentTime,proto3" json:"current_time,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	Username      string                 `protobuf:"bytes,3,opt,name=username,proto3" json:"username,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GameLog) Reset() {
+	*x = GameLog{}
+	mi := &file_routing_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GameLog) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GameLog) ProtoMessage() {}
+
+func (x *GameLog) ProtoReflect() protoreflect.Message {
+	mi := &file_routing_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GameLog.ProtoReflect.Descriptor instead.
+func (*GameLog) Descriptor() ([]byte, []int) {
+	return file_routing_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GameLog) GetCurrentTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CurrentTime
+	}
+	return nil
+}
+
+func (x *GameLog) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *GameLog) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+// Sent with routing key game_state to peril_direct as an RPC request; the
+// server answers with the current PlayingState.
+type GameStateQuery struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GameStateQuery) Reset() {
+	*x = GameStateQuery{}
+	mi := &file_routing_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GameStateQuery) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GameStateQuery) ProtoMessage() {}
+
+func (x *GameStateQuery) ProtoReflect() protoreflect.Message {
+	mi := &file_routing_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GameStateQuery.ProtoReflect.Descriptor instead.
+func (*GameStateQuery) Descriptor() ([]byte, []int) {
+	return file_routing_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GameStateQuery) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+var File_routing_proto protoreflect.FileDescriptor
+
+const file_routing_proto_rawDesc = "" +
+	"\n" +
+	"\rrouting.proto\x12\rperil.routing\x1a\x1fgoogle/protobuf/timestamp.proto\"+\n" +
+	"\fPlayingState\x12\x1b\n" +
+	"\tis_paused\x18\x01 \x01(\bR\bisPaused\"~\n" +
+	"\aGameLog\x12=\n" +
+	"\fcurrent_time\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\vcurrentTime\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12\x1a\n" +
+	"\busername\x18\x03 \x01(\tR\busername\",\n" +
+	"\x0eGameStateQuery\x12\x1a\n" +
+	"\busername\x18\x01 \x01(\tR\busernameBHZFgithub.com/bootdotdev/learn-pub-sub-starter/internal/routing/routingpbb\x06proto3"
+
+var (
+	file_routing_proto_rawDescOnce sync.Once
+	file_routing_proto_rawDescData []byte
+)
+
+func file_routing_proto_rawDescGZIP() []byte {
+	file_routing_proto_rawDescOnce.Do(func() {
+		file_routing_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_routing_proto_rawDesc), len(file_routing_proto_rawDesc)))
+	})
+	return file_routing_proto_rawDescData
+}
+
+var file_routing_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_routing_proto_goTypes = []any{
+	(*PlayingState)(nil),          // 0: peril.routing.PlayingState
+	(*GameLog)(nil),               // 1: peril.routing.GameLog
+	(*GameStateQuery)(nil),        // 2: peril.routing.GameStateQuery
+	(*timestamppb.Timestamp)(nil), // 3: google.protobuf.Timestamp
+}
+var file_routing_proto_depIdxs = []int32{
+	3, // 0: peril.routing.GameLog.current_time:type_name -> google.protobuf.Timestamp
+	1, // [1:1] is the sub-list for method output_type
+	1, // [1:1] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_routing_proto_init() }
+func file_routing_proto_init() {
+	if File_routing_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_routing_proto_rawDesc), len(file_routing_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   3,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_routing_proto_goTypes,
+		DependencyIndexes: file_routing_proto_depIdxs,
+		MessageInfos:      file_routing_proto_msgTypes,
+	}.Build()
+	File_routing_proto = out.File
+	file_routing_proto_goTypes = nil
+	file_routing_proto_depIdxs = nil
+}
@@ -0,0 +1,121 @@
+package topology
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Inspector reads the topology a broker actually has.
+type Inspector interface {
+	Inspect(ctx context.Context) (Topology, error)
+}
+
+// ManagementAPI inspects a RabbitMQ node through its management plugin's
+// HTTP API, e.g. http://localhost:15672.
+type ManagementAPI struct {
+	URL      string
+	Username string
+	Password string
+	VHost    string
+	Client   *http.Client
+}
+
+func (m ManagementAPI) Inspect(ctx context.Context) (Topology, error) {
+	var t Topology
+
+	var exchanges []struct {
+		Name       string `json:"name"`
+		Type       string `json:"type"`
+		Durable    bool   `json:"durable"`
+		AutoDelete bool   `json:"auto_delete"`
+	}
+	err := m.get(ctx, "exchanges", &exchanges)
+	if err != nil {
+		return Topology{}, err
+	}
+	for _, ex := range exchanges {
+		t.Exchanges = append(t.Exchanges, Exchange{
+			Name:       ex.Name,
+			Kind:       ex.Type,
+			Durable:    ex.Durable,
+			AutoDelete: ex.AutoDelete,
+		})
+	}
+
+	var queues []struct {
+		Name       string         `json:"name"`
+		Durable    bool           `json:"durable"`
+		AutoDelete bool           `json:"auto_delete"`
+		Exclusive  bool           `json:"exclusive"`
+		Arguments  map[string]any `json:"arguments"`
+	}
+	err = m.get(ctx, "queues", &queues)
+	if err != nil {
+		return Topology{}, err
+	}
+	for _, q := range queues {
+		t.Queues = append(t.Queues, pubsub.QueueSpec{
+			Name:       q.Name,
+			Durable:    q.Durable,
+			AutoDelete: q.AutoDelete,
+			Exclusive:  q.Exclusive,
+			Args:       amqp.Table(q.Arguments),
+		})
+	}
+
+	var bindings []struct {
+		Source          string `json:"source"`
+		Destination     string `json:"destination"`
+		DestinationType string `json:"destination_type"`
+		RoutingKey      string `json:"routing_key"`
+	}
+	err = m.get(ctx, "bindings", &bindings)
+	if err != nil {
+		return Topology{}, err
+	}
+	for _, b := range bindings {
+		if b.DestinationType != "queue" {
+			continue
+		}
+		t.Bindings = append(t.Bindings, Binding{
+			Exchange: b.Source,
+			Queue:    b.Destination,
+			Key:      b.RoutingKey,
+		})
+	}
+	return t, nil
+}
+
+func (m ManagementAPI) get(ctx context.Context, resource string, v any) error {
+	vhost := m.VHost
+	if vhost == "" {
+		vhost = "/"
+	}
+	u := fmt.Sprintf("%s/api/%s/%s", strings.TrimSuffix(m.URL, "/"), resource, url.PathEscape(vhost))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(m.Username, m.Password)
+
+	client := m.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("management API returned %s for %s", resp.Status, u)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
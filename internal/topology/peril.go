@@ -0,0 +1,133 @@
+package topology
+
+import (
+	"errors"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	GameLogsQueue    = routing.GameLogSlug
+	GameStateQueue   = routing.GameStateQueryKey
+	OrdersQueue      = routing.OrdersPrefix
+	PlayerStateQueue = routing.PlayerStateQueryKey
+	MapQueue         = routing.MapQueryKey
+
+	GameLogsBinding  = string(routing.KindGameLog) + ".*"
+	WarBinding       = string(routing.KindWar) + ".*"
+	ArmyMovesBinding = string(routing.KindArmyMove) + ".*"
+	OrdersBinding    = string(routing.KindOrder) + ".*"
+)
+
+// OrdersConsumed reports whether a server is taking orders. OrdersQueue is
+// durable, so orders published while no server is running would wait there
+// instead of failing. It looks the queue up on a channel of its own, since
+// the broker closes the channel if the queue does not exist yet.
+func OrdersConsumed(conn pubsub.Broker) (bool, error) {
+	ch, err := conn.Channel()
+	if err != nil {
+		return false, err
+	}
+	defer ch.Close()
+	q, err := ch.QueueDeclarePassive(OrdersQueue, true, false, false, false, nil)
+	var amqpErr *amqp.Error
+	if errors.As(err, &amqpErr) && amqpErr.Code == amqp.NotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return q.Consumers > 0, nil
+}
+
+// PauseQueue is the transient queue a player's client receives pause and
+// resume messages on.
+func PauseQueue(username string) string {
+	return routing.UserPauseKey(username)
+}
+
+// RoundQueue is the transient queue a player's client receives round
+// announcements on in turn mode.
+func RoundQueue(username string) string {
+	return routing.UserRoundKey(username)
+}
+
+// GameOverQueue is the transient queue a player's client learns the game
+// has ended on.
+func GameOverQueue(username string) string {
+	return routing.UserGameOverKey(username)
+}
+
+// ArmyMovesQueue is the transient queue a player's client receives every
+// player's moves on.
+func ArmyMovesQueue(username string) string {
+	return routing.ArmyMoveKey(username)
+}
+
+// PlayerUpdatesQueue is the transient queue a player's client receives the
+// server's updates to its army on.
+func PlayerUpdatesQueue(username string) string {
+	return routing.PlayerUpdateKey(username)
+}
+
+// WarsQueue is the transient queue a player's client receives the verdict
+// on every war on.
+func WarsQueue(username string) string {
+	return routing.WarKey(username)
+}
+
+// The queue options below decide the queue arguments, so subscribers to the
+// shared queues must pass them too, or the broker rejects their declaration.
+
+func GameLogsOptions() []pubsub.SubscribeOption {
+	return []pubsub.SubscribeOption{
+		pubsub.WithQuorumQueue(),
+		pubsub.WithMaxLength(10000),
+		pubsub.WithRetry(pubsub.DefaultRetryPolicy),
+	}
+}
+
+// GameStateOptions make the game state, player state and map RPC queues
+// shared by every running server, and gone once none is. Declare them as
+// Transient.
+func GameStateOptions() []pubsub.SubscribeOption {
+	return []pubsub.SubscribeOption{
+		pubsub.WithExclusive(false),
+	}
+}
+
+// DeadLetterOptions turn dead-lettering off for the DLQ itself, or rejected
+// dead letters would loop.
+func DeadLetterOptions() []pubsub.SubscribeOption {
+	return []pubsub.SubscribeOption{
+		pubsub.WithDeadLetter(""),
+	}
+}
+
+// Peril is everything shared by all players. Each client's own transient
+// queues are declared when it subscribes.
+func Peril() Topology {
+	t := Topology{
+		Exchanges: []Exchange{
+			{Name: routing.ExchangePerilDirect, Kind: amqp.ExchangeDirect, Durable: true},
+			{Name: routing.ExchangePerilTopic, Kind: amqp.ExchangeTopic, Durable: true},
+			{Name: routing.ExchangePerilDLX, Kind: amqp.ExchangeFanout, Durable: true},
+		},
+	}
+	t.addQueue(routing.QueuePerilDLQ, DeadLetterOptions(), routing.ExchangePerilDLX, "")
+	t.addQueue(GameLogsQueue, GameLogsOptions(), routing.ExchangePerilTopic, GameLogsBinding)
+	t.addQueue(OrdersQueue, nil, routing.ExchangePerilTopic, OrdersBinding)
+	return t
+}
+
+// addQueue adds a durable queue, its retry queues and its binding.
+func (t *Topology) addQueue(name string, opts []pubsub.SubscribeOption, exchange, key string) {
+	specs, err := pubsub.QueueSpecs(name, pubsub.Durable, opts...)
+	if err != nil {
+		panic(err)
+	}
+	t.Queues = append(t.Queues, specs...)
+	t.Bindings = append(t.Bindings, Binding{Exchange: exchange, Queue: name, Key: key})
+}
@@ -0,0 +1,48 @@
+package topology
+
+import (
+	"testing"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+)
+
+func TestOrdersConsumed(t *testing.T) {
+	s := pubsub.NewMemoryServer()
+	conn := s.Connect()
+	check := func(want bool) {
+		t.Helper()
+		got, err := OrdersConsumed(conn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("OrdersConsumed = %v, want %v", got, want)
+		}
+	}
+
+	// No server has ever declared the queue.
+	check(false)
+
+	ch, err := s.Connect().Channel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	specs, err := pubsub.QueueSpecs(OrdersQueue, pubsub.Durable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := specs[0].Declare(ch); err != nil {
+		t.Fatal(err)
+	}
+	check(false)
+
+	if _, err := ch.Consume(OrdersQueue, "server", false, false, false, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	check(true)
+
+	if err := ch.Close(); err != nil {
+		t.Fatal(err)
+	}
+	check(false)
+}
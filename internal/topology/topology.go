@@ -0,0 +1,200 @@
+// Package topology describes the exchanges, queues and bindings Peril needs
+// on the broker, declares them and compares them with what a broker has.
+package topology
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+type Exchange struct {
+	Name       string
+	Kind       string
+	Durable    bool
+	AutoDelete bool
+}
+
+type Binding struct {
+	Exchange string
+	Queue    string
+	Key      string
+}
+
+type Topology struct {
+	Exchanges []Exchange
+	Queues    []pubsub.QueueSpec
+	Bindings  []Binding
+}
+
+// Declarer is the part of a channel Apply needs.
+type Declarer interface {
+	ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error
+	QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error)
+	QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error
+}
+
+// Apply declares everything in t. Declarations are idempotent, so it is safe
+// to run on every start; it fails with PRECONDITION_FAILED if something
+// already exists with different settings, which Diff can explain.
+func Apply(ch Declarer, t Topology) error {
+	for _, ex := range t.Exchanges {
+		err := ch.ExchangeDeclare(ex.Name, ex.Kind, ex.Durable, ex.AutoDelete, false, false, nil)
+		if err != nil {
+			return fmt.Errorf("declaring exchange %s: %w", ex.Name, err)
+		}
+	}
+	for _, q := range t.Queues {
+		_, err := ch.QueueDeclare(q.Name, q.Durable, q.AutoDelete, q.Exclusive, false, q.Args)
+		if err != nil {
+			return fmt.Errorf("declaring queue %s: %w", q.Name, err)
+		}
+	}
+	for _, b := range t.Bindings {
+		err := ch.QueueBind(b.Queue, b.Key, b.Exchange, false, nil)
+		if err != nil {
+			return fmt.Errorf("binding queue %s to %s with %q: %w", b.Queue, b.Exchange, b.Key, err)
+		}
+	}
+	return nil
+}
+
+// Drift is one difference between a declared topology and a broker's.
+type Drift struct {
+	Kind    string
+	Name    string
+	Problem string
+}
+
+func (d Drift) String() string {
+	return fmt.Sprintf("%s %s: %s", d.Kind, d.Name, d.Problem)
+}
+
+// Diff reports what in got differs from want. Only the exchanges and queues
+// want declares are compared; anything else on the broker, such as players'
+// transient queues, is ignored.
+func Diff(want, got Topology) []Drift {
+	var drift []Drift
+
+	exchanges := map[string]Exchange{}
+	for _, ex := range got.Exchanges {
+		exchanges[ex.Name] = ex
+	}
+	for _, ex := range want.Exchanges {
+		have, ok := exchanges[ex.Name]
+		if !ok {
+			drift = append(drift, Drift{"exchange", ex.Name, "missing"})
+			continue
+		}
+		if have.Kind != ex.Kind {
+			drift = append(drift, Drift{"exchange", ex.Name, fmt.Sprintf("type is %s, want %s", have.Kind, ex.Kind)})
+		}
+		if have.Durable != ex.Durable {
+			drift = append(drift, Drift{"exchange", ex.Name, fmt.Sprintf("durable is %t, want %t", have.Durable, ex.Durable)})
+		}
+		if have.AutoDelete != ex.AutoDelete {
+			drift = append(drift, Drift{"exchange", ex.Name, fmt.Sprintf("auto-delete is %t, want %t", have.AutoDelete, ex.AutoDelete)})
+		}
+	}
+
+	queues := map[string]pubsub.QueueSpec{}
+	for _, q := range got.Queues {
+		queues[q.Name] = q
+	}
+	for _, q := range want.Queues {
+		have, ok := queues[q.Name]
+		if !ok {
+			drift = append(drift, Drift{"queue", q.Name, "missing"})
+			continue
+		}
+		if have.Durable != q.Durable {
+			drift = append(drift, Drift{"queue", q.Name, fmt.Sprintf("durable is %t, want %t", have.Durable, q.Durable)})
+		}
+		if have.AutoDelete != q.AutoDelete {
+			drift = append(drift, Drift{"queue", q.Name, fmt.Sprintf("auto-delete is %t, want %t", have.AutoDelete, q.AutoDelete)})
+		}
+		if have.Exclusive != q.Exclusive {
+			drift = append(drift, Drift{"queue", q.Name, fmt.Sprintf("exclusive is %t, want %t", have.Exclusive, q.Exclusive)})
+		}
+		for _, problem := range diffArgs(q.Args, have.Args) {
+			drift = append(drift, Drift{"queue", q.Name, problem})
+		}
+	}
+
+	for _, b := range want.Bindings {
+		if !slices.Contains(got.Bindings, b) {
+			drift = append(drift, Drift{"binding", bindingName(b), "missing"})
+		}
+	}
+	// Extra bindings between our own exchanges and queues route messages
+	// somewhere the declaration does not expect.
+	for _, b := range got.Bindings {
+		if declares(want, b) && !slices.Contains(want.Bindings, b) {
+			drift = append(drift, Drift{"binding", bindingName(b), "not declared"})
+		}
+	}
+	return drift
+}
+
+func declares(t Topology, b Binding) bool {
+	hasQueue := slices.ContainsFunc(t.Queues, func(q pubsub.QueueSpec) bool { return q.Name == b.Queue })
+	hasExchange := slices.ContainsFunc(t.Exchanges, func(ex Exchange) bool { return ex.Name == b.Exchange })
+	return hasQueue && hasExchange
+}
+
+func bindingName(b Binding) string {
+	return fmt.Sprintf("%s -> %s (%q)", b.Exchange, b.Queue, b.Key)
+}
+
+// diffArgs compares queue arguments. Numbers are compared by value, since
+// the management API reports them all as JSON numbers.
+func diffArgs(want, got amqp.Table) []string {
+	keys := map[string]struct{}{}
+	for k := range want {
+		keys[k] = struct{}{}
+	}
+	for k := range got {
+		keys[k] = struct{}{}
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	var problems []string
+	for _, k := range sorted {
+		w, wok := want[k]
+		g, gok := got[k]
+		switch {
+		case !gok:
+			problems = append(problems, fmt.Sprintf("argument %s is missing, want %v", k, w))
+		case !wok:
+			problems = append(problems, fmt.Sprintf("argument %s is %v, want it unset", k, g))
+		case normalizeArg(w) != normalizeArg(g):
+			problems = append(problems, fmt.Sprintf("argument %s is %v, want %v", k, g, w))
+		}
+	}
+	return problems
+}
+
+func normalizeArg(v any) any {
+	switch n := v.(type) {
+	case int:
+		return int64(n)
+	case int16:
+		return int64(n)
+	case int32:
+		return int64(n)
+	case int64:
+		return n
+	case float64:
+		if n == float64(int64(n)) {
+			return int64(n)
+		}
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,195 @@
+package topology
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// newManagementAPI serves t the way RabbitMQ's management plugin does,
+// numbers and all, and returns an API that inspects it.
+func newManagementAPI(t *testing.T, topo Topology) ManagementAPI {
+	t.Helper()
+	var exchanges, queues, bindings []map[string]any
+	for _, ex := range topo.Exchanges {
+		exchanges = append(exchanges, map[string]any{
+			"name": ex.Name, "type": ex.Kind, "durable": ex.Durable, "auto_delete": ex.AutoDelete,
+		})
+	}
+	for _, q := range topo.Queues {
+		queues = append(queues, map[string]any{
+			"name": q.Name, "durable": q.Durable, "auto_delete": q.AutoDelete, "exclusive": q.Exclusive, "arguments": q.Args,
+		})
+	}
+	for _, b := range topo.Bindings {
+		bindings = append(bindings, map[string]any{
+			"source": b.Exchange, "destination": b.Queue, "destination_type": "queue", "routing_key": b.Key,
+		})
+	}
+	// Exchange-to-exchange bindings are not part of a topology.
+	bindings = append(bindings, map[string]any{
+		"source": "a", "destination": "b", "destination_type": "exchange", "routing_key": "#",
+	})
+	resources := map[string]any{
+		"/api/exchanges/%2F": exchanges,
+		"/api/queues/%2F":    queues,
+		"/api/bindings/%2F":  bindings,
+	}
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "guest" || pass != "guest" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		body, ok := resources[r.URL.EscapedPath()]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(body)
+	}))
+	t.Cleanup(s.Close)
+	return ManagementAPI{URL: s.URL + "/", Username: "guest", Password: "guest", Client: s.Client()}
+}
+
+func TestInspectMatchesPeril(t *testing.T) {
+	want := Peril()
+	got, err := newManagementAPI(t, want).Inspect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if drift := Diff(want, got); len(drift) != 0 {
+		t.Errorf("the declared topology drifted from itself: %v", drift)
+	}
+	if len(got.Bindings) != len(want.Bindings) {
+		t.Errorf("got %d bindings, want %d", len(got.Bindings), len(want.Bindings))
+	}
+}
+
+func TestInspectFails(t *testing.T) {
+	api := newManagementAPI(t, Peril())
+	api.Password = "wrong"
+	if _, err := api.Inspect(context.Background()); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("got %v, want a 401", err)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(got *Topology)
+		want   []string
+	}{
+		{"same", func(*Topology) {}, nil},
+		{"missing exchange", func(got *Topology) {
+			got.Exchanges = got.Exchanges[1:]
+		}, []string{"exchange peril_direct: missing"}},
+		{"exchange settings", func(got *Topology) {
+			got.Exchanges[0].Kind = amqp.ExchangeTopic
+			got.Exchanges[0].Durable = false
+			got.Exchanges[0].AutoDelete = true
+		}, []string{
+			"exchange peril_direct: type is topic, want direct",
+			"exchange peril_direct: durable is false, want true",
+			"exchange peril_direct: auto-delete is true, want false",
+		}},
+		{"missing queue", func(got *Topology) {
+			got.Queues = slices.DeleteFunc(got.Queues, func(q pubsub.QueueSpec) bool { return q.Name == OrdersQueue })
+		}, []string{"queue orders: missing"}},
+		{"queue settings", func(got *Topology) {
+			q := queueNamed(got, OrdersQueue)
+			q.Durable, q.AutoDelete, q.Exclusive = false, true, true
+		}, []string{
+			"queue orders: durable is false, want true",
+			"queue orders: auto-delete is true, want false",
+			"queue orders: exclusive is true, want false",
+		}},
+		{"queue arguments", func(got *Topology) {
+			q := queueNamed(got, GameLogsQueue)
+			q.Args = maps.Clone(q.Args)
+			q.Args["x-max-length"] = 5
+		}, []string{"queue game_logs: argument x-max-length is 5, want 10000"}},
+		{"missing binding", func(got *Topology) {
+			got.Bindings = slices.DeleteFunc(got.Bindings, func(b Binding) bool { return b.Queue == OrdersQueue })
+		}, []string{`binding peril_topic -> orders ("orders.*"): missing`}},
+		{"extra binding", func(got *Topology) {
+			got.Bindings = append(got.Bindings, Binding{Exchange: "peril_topic", Queue: OrdersQueue, Key: "#"})
+		}, []string{`binding peril_topic -> orders ("#"): not declared`}},
+		{"someone else's binding", func(got *Topology) {
+			got.Bindings = append(got.Bindings, Binding{Exchange: "peril_topic", Queue: "army_moves.alice", Key: "army_moves.*"})
+			got.Queues = append(got.Queues, pubsub.QueueSpec{Name: "army_moves.alice", AutoDelete: true, Exclusive: true})
+		}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Peril()
+			tt.change(&got)
+			var problems []string
+			for _, d := range Diff(Peril(), got) {
+				problems = append(problems, d.String())
+			}
+			if !reflect.DeepEqual(problems, tt.want) {
+				t.Errorf("got %q, want %q", problems, tt.want)
+			}
+		})
+	}
+}
+
+func queueNamed(t *Topology, name string) *pubsub.QueueSpec {
+	i := slices.IndexFunc(t.Queues, func(q pubsub.QueueSpec) bool { return q.Name == name })
+	return &t.Queues[i]
+}
+
+func TestDiffArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		want amqp.Table
+		got  amqp.Table
+		diff []string
+	}{
+		{"both empty", nil, amqp.Table{}, nil},
+		{"int and int64", amqp.Table{"x-max-length": 10}, amqp.Table{"x-max-length": int64(10)}, nil},
+		{"int32 and JSON number", amqp.Table{"x-message-ttl": int32(500)}, amqp.Table{"x-message-ttl": float64(500)}, nil},
+		{"strings", amqp.Table{"x-queue-type": "quorum"}, amqp.Table{"x-queue-type": "quorum"}, nil},
+		{"different number", amqp.Table{"x-max-length": 10}, amqp.Table{"x-max-length": float64(11)}, []string{"argument x-max-length is 11, want 10"}},
+		{"fraction", amqp.Table{"x-max-length": 10}, amqp.Table{"x-max-length": 10.5}, []string{"argument x-max-length is 10.5, want 10"}},
+		{"different string", amqp.Table{"x-queue-type": "quorum"}, amqp.Table{"x-queue-type": "classic"}, []string{"argument x-queue-type is classic, want quorum"}},
+		{"missing", amqp.Table{"x-max-length": 10}, nil, []string{"argument x-max-length is missing, want 10"}},
+		{"extra", nil, amqp.Table{"x-dead-letter-exchange": "peril_dlx"}, []string{"argument x-dead-letter-exchange is peril_dlx, want it unset"}},
+		{
+			"sorted by name",
+			amqp.Table{"b": 1, "a": 1},
+			amqp.Table{"b": 2, "a": 2},
+			[]string{"argument a is 2, want 1", "argument b is 2, want 1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := diffArgs(tt.want, tt.got); !reflect.DeepEqual(diff, tt.diff) {
+				t.Errorf("got %q, want %q", diff, tt.diff)
+			}
+		})
+	}
+}
+
+func TestApplyIsIdempotent(t *testing.T) {
+	ch, err := pubsub.NewMemoryServer().Connect().Channel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ch.Close()
+	for range 2 {
+		if err := Apply(ch, Peril()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}